@@ -0,0 +1,57 @@
+package djson
+
+import (
+	"regexp"
+	"strings"
+)
+
+var anchorName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Parse the value as an anchor definition, a name prefixed with '&' followed
+// by spaces and the anchored value, e.g. "&b nested:host=x" with
+// WithNestedValues or "&b {host=x}" with WithOrderedValues.
+func parseAnchor(val string) (string, string, bool) {
+	if !strings.HasPrefix(val, "&") {
+		return "", "", false
+	}
+	i := strings.IndexByte(val, ' ')
+	if i < 0 || !anchorName.MatchString(val[1:i]) {
+		return "", "", false
+	}
+	return val[1:i], strings.TrimLeft(val[i:], " "), true
+}
+
+// Parse the value as an alias, a name prefixed with '*', e.g. "*b".
+func parseAlias(val string) (string, bool) {
+	if !strings.HasPrefix(val, "*") || !anchorName.MatchString(val[1:]) {
+		return "", false
+	}
+	return val[1:], true
+}
+
+// Copy the maps and arrays of the value, so that merging into the copy
+// leaves the original intact.
+func copyValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, e := range v {
+			m[key] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = copyValue(e)
+		}
+		return a
+	case *OrderedMap:
+		m := NewOrderedMap()
+		for _, key := range v.keys {
+			m.Set(key, copyValue(v.values[key]))
+		}
+		return m
+	default:
+		return val
+	}
+}
@@ -0,0 +1,132 @@
+package djson
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Node is a node of the abstract syntax tree of an assignment.
+type Node interface {
+	node()
+}
+
+// KeyNode is a map key in the path of an assignment.
+type KeyNode struct {
+	Key  string
+	Next Node
+}
+
+// IndexNode is an array index in the path of an assignment.
+type IndexNode struct {
+	Index int
+	Next  Node
+}
+
+// RangeNode is an inclusive range of array indices in the path of an
+// assignment, the rest of the path applying to every index of the range.
+type RangeNode struct {
+	First, Last int
+	Next        Node
+}
+
+// ValueNode is the value assigned at the end of the path.
+type ValueNode struct {
+	Value interface{}
+}
+
+func (*KeyNode) node()   {}
+func (*IndexNode) node() {}
+func (*RangeNode) node() {}
+func (*ValueNode) node() {}
+
+// ParseAST deserializes the input string like MergeValue does, returning the
+// abstract syntax tree of the assignment instead of merging it to a map.
+func ParseAST(str string, opts ...Option) (Node, error) {
+	parser := newParser(str, opts)
+	parser.rightValueReader = parser.readRightValue
+	return parser.parseAST(str)
+}
+
+func (p *parser) parseAST(str string) (Node, error) {
+	b := &astBuilder{next: new(Node)}
+	if err := p.merge(b, str); err != nil {
+		return nil, err
+	}
+	if p.assignments > 1 {
+		return nil, fmt.Errorf("unable to parse \"%s\", expecting a single assignment", str)
+	}
+	return *b.next, nil
+}
+
+// Evaluate merges the assignments represented by the abstract syntax trees to
+// the map provided, in the order of the trees.
+func Evaluate(m map[string]interface{}, nodes ...Node) error {
+	for _, n := range nodes {
+		k, ok := n.(*KeyNode)
+		if !ok {
+			return fmt.Errorf("unable to evaluate %T, expecting a key node", n)
+		}
+		if err := evaluate(newRootBuilder(m).newMapBuilder(k.Key), k.Next); err != nil {
+			return fmt.Errorf("unable to evaluate \"%s\", %v", k.Key, err)
+		}
+	}
+	return nil
+}
+
+func evaluate(b builder, n Node) error {
+	switch n := n.(type) {
+	case *KeyNode:
+		return evaluate(b.newMapBuilder(n.Key), n.Next)
+	case *IndexNode:
+		return evaluate(b.newArrayBuilder(n.Index), n.Next)
+	case *RangeNode:
+		if n.First < 0 {
+			return fmt.Errorf("negative array index range [%d:%d]", n.First, n.Last)
+		}
+		if n.Last < n.First {
+			return fmt.Errorf("reversed array index range [%d:%d]", n.First, n.Last)
+		}
+		indices := make([]int, 0, n.Last-n.First+1)
+		for i := n.First; i <= n.Last; i++ {
+			indices = append(indices, i)
+		}
+		return evaluate(newRangeBuilder(b, indices), n.Next)
+	case *ValueNode:
+		b.set(n.Value)
+		return nil
+	default:
+		return errors.New("missing value node")
+	}
+}
+
+// A builder recording the path of an assignment as nodes instead of building
+// a map.
+type astBuilder struct {
+	next *Node
+}
+
+func (b *astBuilder) newMapBuilder(key string) builder {
+	n := &KeyNode{Key: key}
+	*b.next = n
+	return &astBuilder{next: &n.Next}
+}
+
+func (b *astBuilder) newArrayBuilder(index int) builder {
+	n := &IndexNode{Index: index}
+	*b.next = n
+	return &astBuilder{next: &n.Next}
+}
+
+func (b *astBuilder) newRangeBuilder(first, last int) builder {
+	n := &RangeNode{First: first, Last: last}
+	*b.next = n
+	return &astBuilder{next: &n.Next}
+}
+
+func (b *astBuilder) get() (interface{}, bool) {
+	return nil, false
+}
+
+func (b *astBuilder) set(val interface{}) {
+	*b.next = &ValueNode{Value: val}
+}
@@ -0,0 +1,130 @@
+package djson
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ParseAST_Returns_Nodes(t *testing.T) {
+	input := "foo[0].bar=baz"
+	n, err := ParseAST(input)
+	if err != nil {
+		t.Fatalf("Expected success for \"%s\", got %v", input, err)
+	}
+	expected := &KeyNode{
+		Key: "foo",
+		Next: &IndexNode{
+			Index: 0,
+			Next: &KeyNode{
+				Key:  "bar",
+				Next: &ValueNode{Value: "baz"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("\nIn the case of \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v", input, expected, n)
+	}
+
+	input = "foo[0:2].bar=x"
+	n, err = ParseAST(input, WithIndexRanges())
+	if err != nil {
+		t.Fatalf("Expected success for \"%s\", got %v", input, err)
+	}
+	expected = &KeyNode{
+		Key: "foo",
+		Next: &RangeNode{
+			First: 0,
+			Last:  2,
+			Next: &KeyNode{
+				Key:  "bar",
+				Next: &ValueNode{Value: "x"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(n, expected) {
+		t.Errorf("\nIn the case of \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v", input, expected, n)
+	}
+
+	errorTest := newParserErrorTestCase(
+		"an invalid path", "foo.=bar",
+		"unable to parse \"foo.=bar\", in position 5 got unexpected character: U+003D '=', expecting a map key",
+	)
+	_, err = ParseAST(errorTest.input)
+	assertError(t, err, errorTest)
+
+	errorTest = newParserErrorTestCase(
+		"several assignments", "foo=bar,baz=qux",
+		"unable to parse \"foo=bar,baz=qux\", expecting a single assignment",
+	)
+	_, err = ParseAST(errorTest.input)
+	assertError(t, err, errorTest)
+}
+
+func Test_Evaluate_Merges_Nodes(t *testing.T) {
+	var nodes []Node
+	for _, input := range []string{"foo[0].bar=baz", "foo[0].qux=10", "foo[2]=true"} {
+		n, err := ParseAST(input)
+		if err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", input, err)
+		}
+		nodes = append(nodes, n)
+	}
+	test := newParserTestCase(
+		"evaluating nodes", "foo[0].bar=baz,foo[0].qux=10,foo[2]=true",
+		map[string]interface{}{
+			"foo": []interface{}{
+				map[string]interface{}{
+					"bar": "baz",
+					"qux": int64(10),
+				},
+				nil,
+				true,
+			},
+		},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, Evaluate(m, nodes...), test, m)
+
+	n, err := ParseAST("foo[1:2]=x", WithIndexRanges())
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	test = newParserTestCase(
+		"evaluating a range node", "foo[1:2]=x",
+		map[string]interface{}{
+			"foo": []interface{}{nil, "x", "x"},
+		},
+	)
+	m = map[string]interface{}{}
+	assertNoError(t, Evaluate(m, n), test, m)
+
+	errorTestCases := []struct {
+		parserErrorTestCase
+		node Node
+	}{
+		{
+			newParserErrorTestCase(
+				"a value at the top level", "",
+				"unable to evaluate *djson.ValueNode, expecting a key node",
+			),
+			&ValueNode{Value: "x"},
+		},
+		{
+			newParserErrorTestCase(
+				"a missing value", "",
+				"unable to evaluate \"foo\", missing value node",
+			),
+			&KeyNode{Key: "foo", Next: &IndexNode{Index: 1}},
+		},
+		{
+			newParserErrorTestCase(
+				"a reversed range", "",
+				"unable to evaluate \"foo\", reversed array index range [2:0]",
+			),
+			&KeyNode{Key: "foo", Next: &RangeNode{First: 2, Last: 0, Next: &ValueNode{Value: "x"}}},
+		},
+	}
+	for _, test := range errorTestCases {
+		assertError(t, Evaluate(map[string]interface{}{}, test.node), test.parserErrorTestCase)
+	}
+}
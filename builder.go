@@ -12,10 +12,35 @@ type setter interface {
 	set(val interface{})
 }
 
+type getter interface {
+	get() (val interface{}, ok bool)
+}
+
+type sizer interface {
+	size() int
+}
+
+type elementer interface {
+	elements() (a []interface{}, index int)
+}
+
+type trimmer interface {
+	trimNils()
+}
+
+type deleter interface {
+	delete()
+}
+
+type ranger interface {
+	newRangeBuilder(first, last int) builder
+}
+
 type builder interface {
 	mapBuilderFactory
 	arrayBuilderFactory
 	setter
+	getter
 }
 
 type rootBuilder struct {
@@ -62,11 +87,22 @@ func (b *mapBuilder) newArrayBuilder(index int) builder {
 	return &arrayBuilder{a: a, index: index, parent: b}
 }
 
+func (b *mapBuilder) get() (interface{}, bool) {
+	val, ok := b.m[b.key]
+	return val, ok
+}
+
 func (b *mapBuilder) set(val interface{}) {
 	b.m[b.key] = val
 	b.parent.set(b.m)
 }
 
+// Deleting a key of a map stored in the parent changes it in place, while the
+// map of a missing path is not stored anywhere, making the deletion a no-op.
+func (b *mapBuilder) delete() {
+	delete(b.m, b.key)
+}
+
 type arrayBuilder struct {
 	a      []interface{}
 	index  int
@@ -93,6 +129,36 @@ func (b *arrayBuilder) newArrayBuilder(index int) builder {
 	return &arrayBuilder{a: a, index: index, parent: b}
 }
 
+func (b *arrayBuilder) elements() ([]interface{}, int) {
+	return b.a, b.index
+}
+
+// Remove the trailing nil elements of the array.
+func (b *arrayBuilder) trimNils() {
+	n := len(b.a)
+	for n > 0 && b.a[n-1] == nil {
+		n--
+	}
+	b.a = b.a[:n]
+	b.parent.set(b.a)
+}
+
+func (b *arrayBuilder) size() int {
+	return len(b.a)
+}
+
+func (b *arrayBuilder) get() (interface{}, bool) {
+	if len(b.a) >= b.index+1 {
+		return b.a[b.index], true
+	}
+	return nil, false
+}
+
+// Assigning an element within the length of an existing array writes it
+// in place, so a slice provided by the caller observes the change. Growing the
+// array appends to it, which reuses the backing array of the caller's slice
+// when the capacity allows and reallocates otherwise. In both cases the slice
+// header stored in the parent is the only one reflecting the new length.
 func (b *arrayBuilder) set(val interface{}) {
 	if len(b.a) < b.index+1 {
 		add := b.index + 1 - len(b.a)
@@ -101,3 +167,71 @@ func (b *arrayBuilder) set(val interface{}) {
 	b.a[b.index] = val
 	b.parent.set(b.a)
 }
+
+// Remove the element shifting the following ones, unless the index is out of
+// the array.
+func (b *arrayBuilder) delete() {
+	if b.index < len(b.a) {
+		b.a = append(b.a[:b.index], b.a[b.index+1:]...)
+		b.parent.set(b.a)
+	}
+}
+
+// A builder of the elements at several indices of an array. Building the
+// element at one index can reallocate the array, so the path to every element
+// is only built when the value is set, one element after another.
+type rangeBuilder struct {
+	parent  builder
+	indices []int
+	steps   []func(builder) builder
+}
+
+func newRangeBuilder(parent builder, indices []int) *rangeBuilder {
+	return &rangeBuilder{parent: parent, indices: indices}
+}
+
+func (b *rangeBuilder) then(step func(builder) builder) builder {
+	steps := append(append([]func(builder) builder(nil), b.steps...), step)
+	return &rangeBuilder{parent: b.parent, indices: b.indices, steps: steps}
+}
+
+func (b *rangeBuilder) newMapBuilder(key string) builder {
+	return b.then(func(e builder) builder {
+		return e.newMapBuilder(key)
+	})
+}
+
+func (b *rangeBuilder) newArrayBuilder(index int) builder {
+	return b.then(func(e builder) builder {
+		return e.newArrayBuilder(index)
+	})
+}
+
+func (b *rangeBuilder) element(index int) builder {
+	e := b.parent.newArrayBuilder(index)
+	for _, step := range b.steps {
+		e = step(e)
+	}
+	return e
+}
+
+// The value of the element at the first index stands for the whole range.
+func (b *rangeBuilder) get() (interface{}, bool) {
+	return b.element(b.indices[0]).get()
+}
+
+func (b *rangeBuilder) set(val interface{}) {
+	for _, index := range b.indices {
+		b.element(index).set(val)
+	}
+}
+
+// The elements are deleted from the last one, so that removing an element
+// does not shift the ones still to be deleted.
+func (b *rangeBuilder) delete() {
+	for i := len(b.indices) - 1; i >= 0; i-- {
+		if d, ok := b.element(b.indices[i]).(deleter); ok {
+			d.delete()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package djson
+
+import (
+	"fmt"
+)
+
+// CursorState is what the input fed to a Cursor expects next.
+type CursorState int
+
+const (
+	// CursorKey is inside a map key or expects one.
+	CursorKey CursorState = iota
+	// CursorIndex is inside square brackets or expects an array index.
+	CursorIndex
+	// CursorPathEnd follows an array index and expects '.', '=' or '['.
+	CursorPathEnd
+	// CursorValue is inside the value of an assignment.
+	CursorValue
+)
+
+// CursorStatus reports where the input fed to a Cursor ends.
+type CursorStatus struct {
+	Path   string      // The complete elements of the path, e.g. "foo[0]"
+	State  CursorState // What the input expects next
+	Prefix string      // The incomplete key, index or value the input ends with
+}
+
+// Cursor lexes an input fed incrementally, e.g. while it is being typed in an
+// interactive shell, reporting the path recognized so far and what the input
+// expects next. Unlike MergeValue, it does not fail on input which is an
+// incomplete prefix of a valid assignment, so that "foo[0]." reports the path
+// "foo[0]" expecting a map key. Paths of assignments separated by commas are
+// reported for the last assignment.
+type Cursor struct {
+	input string
+	opts  options
+}
+
+// NewCursor creates a cursor lexing the input with the options provided.
+func NewCursor(opts ...Option) *Cursor {
+	return &Cursor{
+		opts: newOptions(opts),
+	}
+}
+
+// Feed appends the string to the input fed so far, returning the status of
+// the whole input. It fails if the input cannot be completed to a valid
+// assignment.
+func (c *Cursor) Feed(str string) (CursorStatus, error) {
+	c.input += str
+	lex := newLex(c.input, c.opts)
+	defer lex.close()
+	var status CursorStatus
+	for {
+		tok := lex.nextToken()
+		switch tok.TokenType {
+		case tokenMapKey:
+			status.Prefix, status.State = tok.value, CursorKey
+		case tokenMapKeySeparator:
+			if status.State == CursorKey {
+				status.Path = appendKey(status.Path, status.Prefix)
+			}
+			status.Prefix, status.State = "", CursorKey
+		case tokenArrayIndexStart:
+			if status.State == CursorKey {
+				status.Path = appendKey(status.Path, status.Prefix)
+			}
+			status.Prefix, status.State = "", CursorIndex
+		case tokenArrayIndex, tokenArrayKey, tokenArrayRangeSeparator:
+			status.Prefix += tok.value
+		case tokenArrayIndexFinish:
+			status.Path = appendBracketKey(status.Path, status.Prefix)
+			status.Prefix, status.State = "", CursorPathEnd
+		case tokenAssignment:
+			if status.State == CursorKey {
+				status.Path = appendKey(status.Path, status.Prefix)
+			}
+			status.Prefix, status.State = "", CursorValue
+		case tokenValue, tokenQuotedValue:
+			status.Prefix = tok.value
+		case tokenDeletion:
+			// A trailing '-' is likely a key being typed rather than a deletion
+			if status.State == CursorKey {
+				status.Prefix += tok.value
+			}
+		case tokenAssignmentSeparator:
+			status = CursorStatus{}
+		case tokenEnd:
+			return status, nil
+		case tokenError:
+			if isEndError(tok) {
+				return status, nil
+			}
+			return status, fmt.Errorf("unable to parse \"%s\", %v", c.input, tokenToError(tok))
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package djson
+
+import (
+	"testing"
+)
+
+func Test_Cursor_Reports_Incomplete_States(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected CursorStatus
+	}{
+		{"", CursorStatus{"", CursorKey, ""}},
+		{"fo", CursorStatus{"", CursorKey, "fo"}},
+		{"foo.", CursorStatus{"foo", CursorKey, ""}},
+		{"foo.ba", CursorStatus{"foo", CursorKey, "ba"}},
+		{"foo[", CursorStatus{"foo", CursorIndex, ""}},
+		{"foo[1", CursorStatus{"foo", CursorIndex, "1"}},
+		{"foo[0]", CursorStatus{"foo[0]", CursorPathEnd, ""}},
+		{"foo[0].", CursorStatus{"foo[0]", CursorKey, ""}},
+		{"foo[0].bar=", CursorStatus{"foo[0].bar", CursorValue, ""}},
+		{"foo[0].bar=ba", CursorStatus{"foo[0].bar", CursorValue, "ba"}},
+		{"a\\.b.c", CursorStatus{"a\\.b", CursorKey, "c"}},
+		{"a=1,b[", CursorStatus{"b", CursorIndex, ""}},
+		{"foo.my-", CursorStatus{"foo", CursorKey, "my-"}},
+	}
+	for _, test := range testCases {
+		status, err := NewCursor().Feed(test.input)
+		if err != nil {
+			t.Errorf("In the case of \"%s\" expected success, got %v", test.input, err)
+		} else if status != test.expected {
+			t.Errorf("In the case of \"%s\" expected %+v, got %+v", test.input, test.expected, status)
+		}
+	}
+}
+
+func Test_Cursor_Accumulates_Input(t *testing.T) {
+	c := NewCursor(WithIndexRanges())
+	testCases := []struct {
+		input    string
+		expected CursorStatus
+	}{
+		{"foo", CursorStatus{"", CursorKey, "foo"}},
+		{".", CursorStatus{"foo", CursorKey, ""}},
+		{"bar[0:", CursorStatus{"foo.bar", CursorIndex, "0:"}},
+		{"2]", CursorStatus{"foo.bar[0:2]", CursorPathEnd, ""}},
+		{"=x", CursorStatus{"foo.bar[0:2]", CursorValue, "x"}},
+	}
+	for _, test := range testCases {
+		status, err := c.Feed(test.input)
+		if err != nil {
+			t.Fatalf("Expected success feeding \"%s\", got %v", test.input, err)
+		}
+		if status != test.expected {
+			t.Errorf("Feeding \"%s\" expected %+v, got %+v", test.input, test.expected, status)
+		}
+	}
+}
+
+func Test_Cursor_Fails_On_Invalid_Input(t *testing.T) {
+	testCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an empty key", "foo.=",
+			"unable to parse \"foo.=\", in position 5 got unexpected character: U+003D '=', expecting a map key",
+		),
+		newParserErrorTestCase(
+			"an invalid index", "foo[x",
+			"unable to parse \"foo[x\", in position 5 got unexpected character: U+0078 'x', expecting an array index",
+		),
+	}
+	for _, test := range testCases {
+		_, err := NewCursor().Feed(test.input)
+		assertError(t, err, test)
+	}
+}
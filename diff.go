@@ -0,0 +1,140 @@
+package djson
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MinimalOverrides returns the assignments in the DJSON syntax transforming
+// the base map into the target one when merged with MergeValue, in sorted
+// path order, except for the array elements beyond the length of a target
+// array, which are deleted from the last one. Leaves equal in both maps are
+// omitted, and keys removed from the target are deleted with a trailing '-'.
+// Values are rendered by type, slices as indexed assignments and empty arrays
+// as {}, and strings which MergeValue would convert to other types are
+// quoted. Empty maps, which have no assignment syntax, are skipped, and the
+// values of other types are rendered with fmt.Sprint.
+func MinimalOverrides(base, target map[string]interface{}) []string {
+	var res []string
+	diffMaps("", base, target, &res)
+	return res
+}
+
+func diffMaps(path string, base, target map[string]interface{}, res *[]string) {
+	keys := SortedKeys(target)
+	for key := range base {
+		if _, ok := target[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		diffValues(appendKey(path, key), base, target, key, res)
+	}
+}
+
+func diffValues(path string, base, target map[string]interface{}, key string, res *[]string) {
+	t, inTarget := target[key]
+	b, inBase := base[key]
+	switch {
+	case !inTarget:
+		*res = append(*res, path+"-")
+	case !inBase:
+		overrideLeaves(path, t, res)
+	default:
+		diffValue(path, b, t, res)
+	}
+}
+
+func diffValue(path string, base, target interface{}, res *[]string) {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		if b, ok := base.(map[string]interface{}); ok {
+			diffMaps(path, b, t, res)
+			return
+		}
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok {
+			diffArrays(path, b, t, res)
+			return
+		}
+	}
+	if !reflect.DeepEqual(base, target) {
+		overrideLeaves(path, target, res)
+	}
+}
+
+func diffArrays(path string, base, target []interface{}, res *[]string) {
+	for i, t := range target {
+		if i < len(base) {
+			diffValue(appendIndex(path, i), base[i], t, res)
+		} else {
+			overrideLeaves(appendIndex(path, i), t, res)
+		}
+	}
+	for i := len(base) - 1; i >= len(target); i-- {
+		*res = append(*res, appendIndex(path, i)+"-")
+	}
+}
+
+func overrideLeaves(path string, val interface{}, res *[]string) {
+	walkValue(path, val, func(path string, val interface{}) {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			// Empty maps cannot be assigned
+		case []interface{}:
+			*res = append(*res, path+"={}")
+		default:
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+				overrideLeaves(path, sliceElements(rv), res)
+				return
+			}
+			*res = append(*res, override(path, v))
+		}
+	})
+}
+
+// Copy the elements of a slice or an array of any type.
+func sliceElements(rv reflect.Value) []interface{} {
+	a := make([]interface{}, rv.Len())
+	for i := range a {
+		a[i] = rv.Index(i).Interface()
+	}
+	return a
+}
+
+func override(path string, val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return path + "=null"
+	case float64:
+		return path + "=" + formatFloat(v)
+	case string:
+		return path + "=" + renderString(v)
+	case bool, int64:
+		return path + "=" + fmt.Sprint(v)
+	default:
+		return path + "=" + renderString(fmt.Sprint(v))
+	}
+}
+
+// Format the float so that it is not parsed as an integer.
+func formatFloat(f float64) string {
+	str := strconv.FormatFloat(f, 'g', -1, 64)
+	if strings.Trim(str, "-0123456789") == "" {
+		str += ".0"
+	}
+	return str
+}
+
+// Render the string so that MergeValue keeps it a string, quoting it if it
+// would be converted to another type.
+func renderString(str string) string {
+	if val, ok := tryParse(str, &defaultLiterals).(string); ok && str != "" {
+		return renderValue(val)
+	}
+	return quoteValue(str)
+}
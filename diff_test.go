@@ -0,0 +1,128 @@
+package djson
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_MinimalOverrides(t *testing.T) {
+	newBase := func() map[string]interface{} {
+		return map[string]interface{}{
+			"name": "app",
+			"port": int64(80),
+			"db": map[string]interface{}{
+				"host": "db1",
+				"user": "admin",
+			},
+			"tags":   []interface{}{"a", "b", "c"},
+			"legacy": map[string]interface{}{"on": true},
+		}
+	}
+	testCases := []struct {
+		desc     string
+		target   func(map[string]interface{})
+		expected []string
+	}{
+		{
+			"an unchanged map",
+			func(m map[string]interface{}) {},
+			nil,
+		},
+		{
+			"a single changed leaf",
+			func(m map[string]interface{}) {
+				m["db"].(map[string]interface{})["host"] = "db2"
+			},
+			[]string{"db.host=db2"},
+		},
+		{
+			"a removed key",
+			func(m map[string]interface{}) {
+				delete(m, "legacy")
+			},
+			[]string{"legacy-"},
+		},
+		{
+			"added keys and a shorter array",
+			func(m map[string]interface{}) {
+				m["tags"] = []interface{}{"a", "x"}
+				m["cache"] = map[string]interface{}{"size": int64(10), "empty": []interface{}{}}
+			},
+			[]string{"cache.empty={}", "cache.size=10", "tags[1]=x", "tags[2]-"},
+		},
+		{
+			"a scalar replaced by a map",
+			func(m map[string]interface{}) {
+				m["port"] = map[string]interface{}{"http": int64(80), "tls": nil}
+			},
+			[]string{"port.http=80", "port.tls=null"},
+		},
+		{
+			"values and keys with commas",
+			func(m map[string]interface{}) {
+				m["name"] = "app,web"
+				m["a,b"] = "x"
+			},
+			[]string{"a\\,b=x", "name=app\\,web"},
+		},
+		{
+			"values with backslashes",
+			func(m map[string]interface{}) {
+				m["name"] = "C:\\dir"
+			},
+			[]string{`name="C:\\dir"`},
+		},
+	}
+	for _, test := range testCases {
+		target := newBase()
+		test.target(target)
+		res := MinimalOverrides(newBase(), target)
+		if !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("\nIn the case of %s\nexpected:\n\t%+v\ngot:\n\t%+v",
+				test.desc, test.expected, res)
+		}
+	}
+}
+
+func Test_MinimalOverrides_Transform_Base_Into_Target(t *testing.T) {
+	newBase := func() map[string]interface{} {
+		return map[string]interface{}{
+			"name":   "app",
+			"port":   int64(80),
+			"ratio":  0.5,
+			"db":     map[string]interface{}{"host": "db1", "user": "admin"},
+			"tags":   []interface{}{"a", "b", "c", "d"},
+			"hosts":  []interface{}{"h1"},
+			"legacy": map[string]interface{}{"on": true},
+		}
+	}
+	target := map[string]interface{}{
+		"name":  "10",
+		"port":  "true",
+		"ratio": float64(2),
+		"db": map[string]interface{}{
+			"host":  "null",
+			"pass":  `a,"b"\c`,
+			"empty": "",
+			"list":  "{x,y}",
+			"open":  "a[b,c",
+			"brace": `x{y\z`,
+		},
+		"tags":  []interface{}{"a", "x"},
+		"hosts": map[string]interface{}{"primary": "h1"},
+		"roles": []string{"p", "q"},
+		"quote": `"q"`,
+		"none":  nil,
+		"list":  []interface{}{},
+	}
+	m := newBase()
+	for _, override := range MinimalOverrides(newBase(), target) {
+		if err := MergeValue(m, override); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", override, err)
+		}
+	}
+	target["roles"] = []interface{}{"p", "q"}
+	if !reflect.DeepEqual(m, target) {
+		t.Errorf("\nexpected:\n\t%+v\ngot:\n\t%+v", target, m)
+	}
+}
@@ -0,0 +1,205 @@
+package djson
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Encode returns a compact binary representation of the map provided which
+// Decode turns back into an equal map, preserving the exact types of the
+// values, so that e.g. int64(1) and float64(1) stay distinct unlike in JSON.
+// Every value is tagged with its type and the map keys are written sorted, so
+// equal maps are encoded identically. Only the types produced by the parsing
+// with no options are supported: nil, bool, int64, float64, string, nested
+// maps and arrays; any other type fails the encoding.
+func Encode(m map[string]interface{}) ([]byte, error) {
+	return encode(m, "encode")
+}
+
+// Encode the map naming the action in the errors, so that Hash reports the
+// failures of the encoding it is computed over as its own.
+func encode(m map[string]interface{}, action string) ([]byte, error) {
+	var buf []byte
+	if err := encodeValue(&buf, action, "", m); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Decode returns the map encoded by Encode.
+func Decode(data []byte) (map[string]interface{}, error) {
+	d := &decoder{data: data}
+	val, err := d.decodeValue()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.New("unable to decode, unexpected trailing data")
+	}
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("unable to decode, the value is not a map")
+	}
+	return m, nil
+}
+
+func encodeValue(buf *[]byte, action, path string, val interface{}) error {
+	switch v := val.(type) {
+	case nil:
+		*buf = append(*buf, 'n')
+	case bool:
+		if v {
+			*buf = append(*buf, 't')
+		} else {
+			*buf = append(*buf, 'f')
+		}
+	case int64:
+		*buf = append(*buf, 'i')
+		appendVarint(buf, v)
+	case float64:
+		*buf = append(*buf, 'd')
+		appendUint64(buf, math.Float64bits(v))
+	case string:
+		*buf = append(*buf, 's')
+		encodeString(buf, v)
+	case map[string]interface{}:
+		*buf = append(*buf, 'm')
+		appendUvarint(buf, uint64(len(v)))
+		for _, key := range SortedKeys(v) {
+			encodeString(buf, key)
+			if err := encodeValue(buf, action, appendKey(path, key), v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		*buf = append(*buf, 'a')
+		appendUvarint(buf, uint64(len(v)))
+		for i, e := range v {
+			if err := encodeValue(buf, action, appendIndex(path, i), e); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unable to %s \"%s\", unsupported type %T", action, path, val)
+	}
+	return nil
+}
+
+func encodeString(buf *[]byte, s string) {
+	appendUvarint(buf, uint64(len(s)))
+	*buf = append(*buf, s...)
+}
+
+// The binary.Append* functions need Go 1.19, so the numbers are written to a
+// scratch buffer first.
+
+func appendVarint(buf *[]byte, v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	*buf = append(*buf, b[:n]...)
+}
+
+func appendUvarint(buf *[]byte, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	*buf = append(*buf, b[:n]...)
+}
+
+func appendUint64(buf *[]byte, v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	*buf = append(*buf, b[:]...)
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+var errTruncated = errors.New("unable to decode, unexpected end of data")
+
+func (d *decoder) decodeValue() (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, errTruncated
+	}
+	tag := d.data[d.pos]
+	d.pos++
+	switch tag {
+	case 'n':
+		return nil, nil
+	case 't':
+		return true, nil
+	case 'f':
+		return false, nil
+	case 'i':
+		v, n := binary.Varint(d.data[d.pos:])
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		d.pos += n
+		return v, nil
+	case 'd':
+		if len(d.data)-d.pos < 8 {
+			return nil, errTruncated
+		}
+		u := binary.BigEndian.Uint64(d.data[d.pos:])
+		d.pos += 8
+		return math.Float64frombits(u), nil
+	case 's':
+		return d.decodeString()
+	case 'm':
+		l, err := d.decodeLength()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, l)
+		for i := 0; i < l; i++ {
+			key, err := d.decodeString()
+			if err != nil {
+				return nil, err
+			}
+			if m[key], err = d.decodeValue(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case 'a':
+		l, err := d.decodeLength()
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, l)
+		for i := range arr {
+			if arr[i], err = d.decodeValue(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("unable to decode, unknown type tag '%c'", tag)
+}
+
+func (d *decoder) decodeLength() (int, error) {
+	l, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.pos += n
+	// Every element takes at least a byte, which bounds the allocations.
+	if l > uint64(len(d.data)-d.pos) {
+		return 0, errTruncated
+	}
+	return int(l), nil
+}
+
+func (d *decoder) decodeString() (string, error) {
+	l, err := d.decodeLength()
+	if err != nil {
+		return "", err
+	}
+	s := string(d.data[d.pos : d.pos+l])
+	d.pos += l
+	return s, nil
+}
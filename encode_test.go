@@ -0,0 +1,62 @@
+package djson
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Encode_Round_Trip_Preserves_Types(t *testing.T) {
+	m := mergeAll(t, "a.int=7,a.float=1.0,a.bool=true,a.null=null,a.str=\"1\",b[1][0]=x,b[2].c=-7,d=")
+	data, err := Encode(m)
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	res, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if !reflect.DeepEqual(m, res) {
+		t.Errorf("Expected %#v, got %#v", m, res)
+	}
+	if _, ok := res["a"].(map[string]interface{})["int"].(int64); !ok {
+		t.Errorf("Expected int64, got %T", res["a"].(map[string]interface{})["int"])
+	}
+}
+
+func Test_Encode_Fails_On_Unsupported_Types(t *testing.T) {
+	m := map[string]interface{}{
+		"a": []interface{}{
+			struct{}{},
+		},
+	}
+	_, err := Encode(m)
+	expected := "unable to encode \"a[0]\", unsupported type struct {}"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error \"%s\", got %v", expected, err)
+	}
+}
+
+func Test_Decode_Fails_On_Invalid_Data(t *testing.T) {
+	data, err := Encode(mergeAll(t, "a.b=x,c=1.5"))
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	testCases := []struct {
+		desc string
+		data []byte
+		msg  string
+	}{
+		{"empty data", nil, "unable to decode, unexpected end of data"},
+		{"truncated data", data[:len(data)-1], "unable to decode, unexpected end of data"},
+		{"trailing data", append(data[:len(data):len(data)], 'n'), "unable to decode, unexpected trailing data"},
+		{"an unknown tag", []byte{'x'}, "unable to decode, unknown type tag 'x'"},
+		{"not a map", []byte{'n'}, "unable to decode, the value is not a map"},
+		{"an excessive length", []byte{'m', 0xff, 0x01}, "unable to decode, unexpected end of data"},
+	}
+	for _, test := range testCases {
+		_, err := Decode(test.data)
+		if err == nil || err.Error() != test.msg {
+			t.Errorf("Expected error \"%s\" for %s, got %v", test.msg, test.desc, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package djson
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Hash returns a stable SHA-256 hash of the map provided, so that equal maps
+// hash identically regardless of the order in which they were built. The hash
+// is computed over the output of Encode, which writes the map keys sorted and
+// tags every value with its type, so that e.g. int64(1), float64(1) and "1"
+// hash differently. Only the types produced by the parsing with no options
+// are supported: nil, bool, int64, float64, string, nested maps and arrays;
+// any other type fails the hashing.
+func Hash(m map[string]interface{}) (string, error) {
+	data, err := encode(m, "hash")
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
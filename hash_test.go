@@ -0,0 +1,67 @@
+package djson
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Hash_Is_Independent_Of_Order(t *testing.T) {
+	first := mergeAll(t, "a.b=1,a.c[1]=x,a.c[0]=y,d=null,e=true")
+	second := mergeAll(t, "e=true,d=null,a.c[0]=y,a.c[1]=x,a.b=1")
+	assertHash(t, first, second, true)
+}
+
+func Test_Hash_Distinguishes_Types(t *testing.T) {
+	testCases := []struct {
+		first, second string
+	}{
+		{"a=7", "a=7.0"},
+		{"a=7", "a=\"7\""},
+		{"a=null", "a="},
+		{"a=true", "a=\"true\""},
+		{"a.b=x", "a[0]=x"},
+		{"ab=x", "a.b=x"},
+	}
+	for _, test := range testCases {
+		first := mergeAll(t, test.first)
+		second := mergeAll(t, test.second)
+		assertHash(t, first, second, false)
+	}
+}
+
+func Test_Hash_Fails_On_Unsupported_Types(t *testing.T) {
+	m := map[string]interface{}{
+		"a": []interface{}{
+			struct{}{},
+		},
+	}
+	_, err := Hash(m)
+	expected := "unable to hash \"a[0]\", unsupported type struct {}"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error \"%s\", got %v", expected, err)
+	}
+}
+
+func mergeAll(t *testing.T, input string) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, part := range strings.Split(input, ",") {
+		if err := MergeValue(m, part); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+	return m
+}
+
+func assertHash(t *testing.T, first, second map[string]interface{}, equal bool) {
+	h1, err := Hash(first)
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	h2, err := Hash(second)
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if (h1 == h2) != equal {
+		t.Errorf("Expected hashes of %v and %v to be equal: %t, got %s and %s", first, second, equal, h1, h2)
+	}
+}
@@ -0,0 +1,135 @@
+package djson
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Layered merges assignments from several named sources, e.g. defaults, a
+// file and flags, to a single map, keeping track of the source which set
+// every leaf of the map last.
+type Layered struct {
+	m       map[string]interface{}
+	opts    []Option
+	sources map[string]string
+}
+
+// NewLayered creates a layered merger merging to the map provided with the
+// options provided.
+func NewLayered(m map[string]interface{}, opts ...Option) *Layered {
+	return &Layered{
+		m:       m,
+		opts:    opts,
+		sources: map[string]string{},
+	}
+}
+
+// Merge merges the input strings to the map like MergeValue does, labeling
+// the leaves they set with the source name. The merging stops at the first
+// string failing the parsing, and the leaves set before it keep their labels.
+func (l *Layered) Merge(source string, strs ...string) error {
+	for _, str := range strs {
+		var assigned []string
+		opts := append(append([]Option(nil), l.opts...), func(o *options) {
+			o.assignedPaths = &assigned
+		})
+		err := MergeValue(l.m, str, opts...)
+		l.label(source, assigned)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Map returns the map merged to.
+func (l *Layered) Map() map[string]interface{} {
+	return l.m
+}
+
+// Source returns the name of the source which set the leaf at the path last,
+// the path being written in the DJSON syntax like the paths visited by Walk,
+// e.g. "db.hosts[0]". It returns false if no source set the leaf.
+func (l *Layered) Source(path string) (string, bool) {
+	source, ok := l.sources[path]
+	return source, ok
+}
+
+// Sources returns the names of the sources which set the leaves last per
+// path of the leaves.
+func (l *Layered) Sources() map[string]string {
+	res := make(map[string]string, len(l.sources))
+	for path, source := range l.sources {
+		res[path] = source
+	}
+	return res
+}
+
+// Label the leaves under the paths assigned with the source, keeping the
+// labels of the other leaves still present in the map.
+func (l *Layered) label(source string, assigned []string) {
+	sources := map[string]string{}
+	Walk(l.m, func(path string, _ interface{}) {
+		for _, a := range assigned {
+			if coversPath(a, path) {
+				sources[path] = source
+				return
+			}
+		}
+		if s, ok := l.sources[path]; ok {
+			sources[path] = s
+		}
+	})
+	l.sources = sources
+}
+
+// Check whether the leaf path is the assigned path or lies under it, an
+// index range of the assigned path covering the indices within the range.
+func coversPath(assigned, leaf string) bool {
+	a, b := splitPath(assigned), splitPath(leaf)
+	if len(a) > len(b) {
+		return false
+	}
+	for i, seg := range a {
+		if seg != b[i] && !inRange(seg, b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Split the path into the escaped map keys and the bracketed array indices.
+func splitPath(path string) []string {
+	var segs []string
+	start := 0
+	escaped := false
+	for i, r := range path {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '.' || r == '[':
+			if i > start {
+				segs = append(segs, path[start:i])
+			}
+			start = i
+			if r == '.' {
+				start++
+			}
+		}
+	}
+	return append(segs, path[start:])
+}
+
+// Check whether the bracketed index is within the bracketed index range.
+func inRange(rng, index string) bool {
+	bounds := strings.SplitN(strings.Trim(rng, "[]"), ":", 2)
+	if len(bounds) != 2 {
+		return false
+	}
+	first, err1 := strconv.Atoi(bounds[0])
+	last, err2 := strconv.Atoi(bounds[1])
+	i, err3 := strconv.Atoi(strings.Trim(index, "[]"))
+	return err1 == nil && err2 == nil && err3 == nil && first <= i && i <= last
+}
@@ -0,0 +1,62 @@
+package djson
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Layered_Tracks_Sources(t *testing.T) {
+	l := NewLayered(map[string]interface{}{}, WithIndexRanges())
+	if err := l.Merge("defaults", "db.host=localhost,db.port=5432", "tags[0:2]=x", "name=app"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if err := l.Merge("flags", "db.port=5432", "tags[1]=y", "extra.a=1"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	expected := map[string]string{
+		"db.host": "defaults",
+		"db.port": "flags",
+		"tags[0]": "defaults",
+		"tags[1]": "flags",
+		"tags[2]": "defaults",
+		"name":    "defaults",
+		"extra.a": "flags",
+	}
+	if sources := l.Sources(); !reflect.DeepEqual(sources, expected) {
+		t.Errorf("\nexpected:\n\t%+v\ngot:\n\t%+v", expected, sources)
+	}
+	if source, ok := l.Source("db.port"); !ok || source != "flags" {
+		t.Errorf("Expected the source of db.port to be flags, got %q", source)
+	}
+	if source, ok := l.Source("db"); ok {
+		t.Errorf("Expected no source of a path which is not a leaf, got %q", source)
+	}
+
+	if err := l.Merge("flags", "db=none"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if _, ok := l.Source("db.host"); ok {
+		t.Error("Expected the source of a replaced leaf to be dropped")
+	}
+	if source, _ := l.Source("db"); source != "flags" {
+		t.Errorf("Expected the source of the replacing leaf to be flags, got %q", source)
+	}
+}
+
+func Test_Layered_Keeps_Sources_On_Failure(t *testing.T) {
+	l := NewLayered(map[string]interface{}{})
+	test := newParserErrorTestCase(
+		"a failing source", "b.=2",
+		"unable to parse \"b.=2\", in position 3 got unexpected character: U+003D '=', expecting a map key",
+	)
+	assertError(t, l.Merge("file", "a=5", test.input, "c=3"), test)
+
+	expected := map[string]string{"a": "file"}
+	if sources := l.Sources(); !reflect.DeepEqual(sources, expected) {
+		t.Errorf("\nexpected:\n\t%+v\ngot:\n\t%+v", expected, sources)
+	}
+	if m := l.Map(); !reflect.DeepEqual(m, map[string]interface{}{"a": int64(5)}) {
+		t.Errorf("Expected the assignments before the failure to be kept, got %v", m)
+	}
+}
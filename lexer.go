@@ -2,7 +2,9 @@ package djson
 
 import (
 	"fmt"
+	"strings"
 	"unicode"
+	"unicode/utf16"
 	"unicode/utf8"
 )
 
@@ -23,12 +25,16 @@ func newToken(tokenType tokenType, position int, value string) token {
 }
 
 type lex struct {
-	input    string     // The input string
-	position int        // Current position in the input
-	start    int        // Starting position of the current token
-	width    int        // Width of the last rune read
-	buffer   []rune     // Token buffer
-	tokens   chan token // Channel of parsed tokens
+	input    string           // The input string
+	position int              // Current position in the input
+	start    int              // Starting position of the current token
+	width    int              // Width of the last rune read
+	buffer   []rune           // Token buffer
+	tokens   chan token       // Channel of parsed tokens
+	done     chan struct{}    // Closed when the tokens are not read anymore
+	opts     options          // Parsing options
+	stops    map[strRune]bool // The characters ending a map key
+	scalar   bool             // Whether the input is a single value
 }
 
 type stateFunction func(*lex) stateFunction
@@ -45,30 +51,42 @@ func (r strRune) String() string {
 var end = strRune(0)
 
 const (
-	tokenEnd              tokenType = iota // The end of a string
-	tokenError                             // An error
-	tokenMapKey                            // A map key
-	tokenMapKeySeparator                   // A map key separator '.'
-	tokenArrayIndexStart                   // An array index start '['
-	tokenArrayIndexFinish                  // An array index finish ']'
-	tokenArrayIndex                        // An array index
-	tokenAssignment                        // Assignment operator '='
-	tokenValue                             // A value
-	tokenUnknown                           // An unknown token, should be the last one
+	tokenEnd                 tokenType = iota // The end of a string
+	tokenError                                // An error
+	tokenMapKey                               // A map key
+	tokenMapKeySeparator                      // A map key separator '.'
+	tokenArrayIndexStart                      // An array index start '['
+	tokenArrayIndexFinish                     // An array index finish ']'
+	tokenArrayIndex                           // An array index
+	tokenArrayKey                             // A non-numeric content of square brackets
+	tokenArrayRangeSeparator                  // An array index range separator ':'
+	tokenNegation                             // A negation of a bare key '!'
+	tokenAssignment                           // Assignment operator '='
+	tokenDeletion                             // Deletion operator '-' ending a path
+	tokenValue                                // A value
+	tokenQuotedValue                          // A double quoted value without the quotes
+	tokenAssignmentSeparator                  // An assignment separator ','
+	tokenUnknown                              // An unknown token, should be the last one
 )
 
 var (
 	tokenStrings = map[tokenType]string{
-		tokenEnd:              "tokenEnd",
-		tokenError:            "tokenError",
-		tokenMapKey:           "tokenMapKey",
-		tokenMapKeySeparator:  "tokenMapKeySeparator",
-		tokenArrayIndexStart:  "tokenArrayIndexStart",
-		tokenArrayIndexFinish: "tokenArrayIndexFinish",
-		tokenArrayIndex:       "tokenArrayIndex",
-		tokenAssignment:       "tokenAssignment",
-		tokenValue:            "tokenValue",
-		tokenUnknown:          "tokenUnknown",
+		tokenEnd:                 "tokenEnd",
+		tokenError:               "tokenError",
+		tokenMapKey:              "tokenMapKey",
+		tokenMapKeySeparator:     "tokenMapKeySeparator",
+		tokenArrayIndexStart:     "tokenArrayIndexStart",
+		tokenArrayIndexFinish:    "tokenArrayIndexFinish",
+		tokenArrayIndex:          "tokenArrayIndex",
+		tokenArrayKey:            "tokenArrayKey",
+		tokenArrayRangeSeparator: "tokenArrayRangeSeparator",
+		tokenNegation:            "tokenNegation",
+		tokenAssignment:          "tokenAssignment",
+		tokenDeletion:            "tokenDeletion",
+		tokenValue:               "tokenValue",
+		tokenQuotedValue:         "tokenQuotedValue",
+		tokenAssignmentSeparator: "tokenAssignmentSeparator",
+		tokenUnknown:             "tokenUnknown",
 	}
 )
 
@@ -80,21 +98,44 @@ func (t tokenType) String() string {
 }
 
 type lexer interface {
-	drain()
+	close()
 	nextToken() token
 }
 
-func newLex(input string) lexer {
+func newLex(input string, opts options) lexer {
+	return startLex(input, opts, false)
+}
+
+// Create a lexer lexing the whole input as a single value with no key in
+// front, the commas included.
+func newScalarLex(input string, opts options) lexer {
+	return startLex(input, opts, true)
+}
+
+func startLex(input string, opts options, scalar bool) lexer {
+	opts.syntax = opts.syntax.withDefaults()
 	l := &lex{
 		input:  input,
 		tokens: make(chan token),
+		done:   make(chan struct{}),
+		opts:   opts,
+		stops:  opts.syntax.stopChars(),
+		scalar: scalar,
 	}
 	go l.run()
 	return l
 }
 
-func (l *lex) drain() {
-	for range l.tokens {
+// Abandon the lexing, so that the remaining tokens are discarded instead of
+// blocking the lexer until they are read.
+func (l *lex) close() {
+	close(l.done)
+}
+
+func (l *lex) send(tok token) {
+	select {
+	case l.tokens <- tok:
+	case <-l.done:
 	}
 }
 
@@ -133,11 +174,11 @@ func (l *lex) peek() strRune {
 }
 
 func (l *lex) emit(tokenType tokenType) {
-	l.tokens <- token{
+	l.send(token{
 		TokenType: tokenType,
 		position:  l.start,
 		value:     string(l.buffer),
-	}
+	})
 	l.start = l.position
 	l.buffer = l.buffer[:0]
 }
@@ -148,56 +189,133 @@ func (l *lex) error(format string, args ...interface{}) stateFunction {
 	if l.width > 0 {
 		msg = fmt.Sprintf("in position %d got %s", l.position, msg)
 	}
-	l.tokens <- token{
+	l.send(token{
 		TokenType: tokenError,
 		position:  l.start,
 		value:     msg,
-	}
+	})
 	return nil
 }
 
+// Check whether the token is an error reported at the end of the input, as
+// opposed to an unexpected character. Such errors carry no position.
+func isEndError(tok token) bool {
+	return tok.TokenType == tokenError && !strings.HasPrefix(tok.value, "in position ")
+}
+
 // The main lexing loop.
 func (l *lex) run() {
-	for state := lexMapKey; state != nil; {
+	state := l.firstState()
+	if l.opts.rejectInvalidUTF8 {
+		state = lexValidUTF8
+	}
+	for state != nil {
 		state = state(l)
 	}
 	close(l.tokens)
 }
 
+// Check the whole input is valid UTF-8 before lexing it, reporting the
+// position of the first invalid sequence otherwise.
+func lexValidUTF8(l *lex) stateFunction {
+	for i, r := range l.input {
+		if r == utf8.RuneError {
+			if _, width := utf8.DecodeRuneInString(l.input[i:]); width == 1 {
+				l.start, l.position = i, i
+				l.read()
+				return l.error("an invalid UTF-8 sequence")
+			}
+		}
+	}
+	return l.firstState()
+}
+
+// The state the lexing starts with, the root key or the value of a scalar.
+func (l *lex) firstState() stateFunction {
+	if l.scalar {
+		return lexValue
+	}
+	return lexRootKey
+}
+
+// Lex the negation of a bare key preceding the root key if bare keys are
+// accepted.
+func lexRootKey(l *lex) stateFunction {
+	if l.opts.bareKeyBooleans && l.peek() == '!' {
+		l.read()
+		l.emit(tokenNegation)
+	}
+	return lexMapKey
+}
+
 func lexMapKey(l *lex) stateFunction {
 	switch r := l.read(); {
 	case r == end:
 		return l.error("unexpected %v, expecting a map key", r)
-	case !isStopChar(r, stopLeftValueChars):
+	case !isStopChar(r, l.stops):
 		l.unread()
 	default:
 		return l.error("unexpected %v, expecting a map key", r)
 	}
-	err := l.scan(stopLeftValueChars)
+	err := l.scan(l.stops)
 	if err != nil {
-		return l.error(err.Error())
+		return l.error("%v", err)
+	}
+	if len(l.buffer) > 1 && isDeletion(l.input[l.position-1:]) {
+		// The trailing '-' belongs to the path rather than the key
+		l.position--
+		l.buffer = l.buffer[:len(l.buffer)-1]
 	}
 	l.emit(tokenMapKey)
 	return lexLeftValue
 }
 
+// Check whether the input starts with a deletion operator, a '-' ending the
+// assignment.
+func isDeletion(input string) bool {
+	return len(input) == 1 && input[0] == '-' ||
+		len(input) > 1 && input[0] == '-' && input[1] == ','
+}
+
 func lexLeftValue(l *lex) stateFunction {
+	syntax := l.opts.syntax
 	switch ch := l.read(); {
-	case ch == '.':
+	case rune(ch) == syntax.KeySeparator:
 		l.emit(tokenMapKeySeparator)
 		return lexMapKey
-	case ch == '[':
+	case rune(ch) == syntax.IndexStart:
 		l.emit(tokenArrayIndexStart)
 		return lexArrayIndex
-	case ch == '=':
+	case rune(ch) == syntax.Assignment:
 		l.emit(tokenAssignment)
 		return lexValue
+	case ch == '-' && isDeletion(l.input[l.position-1:]):
+		l.emit(tokenDeletion)
+		return lexValueEnd
+	case ch == end && l.opts.bareKeyBooleans:
+		l.emit(tokenEnd)
+		return nil
+	case ch == ',' && l.opts.bareKeyBooleans:
+		l.emit(tokenAssignmentSeparator)
+		return lexRootKey
 	default:
-		return l.error("unexpected %v, expecting '.', '=' or '['", ch)
+		return l.error("unexpected %v, expecting '%c', '%c' or '%c'", ch,
+			syntax.KeySeparator, syntax.Assignment, syntax.IndexStart)
 	}
 }
 
 func lexArrayIndex(l *lex) stateFunction {
+	if l.opts.durationKeys || l.opts.indexNames != nil {
+		return lexBracketContent
+	}
+	if rune(l.peek()) == l.opts.syntax.IndexFinish {
+		// An empty index appends to the array
+		return lexArrayIndexFinish
+	}
+	if l.peek() == '-' {
+		// A negative index counts from the end of the array
+		l.read()
+	}
 	switch ch := l.read(); {
 	case isArrayIndexChar(ch):
 	default:
@@ -205,29 +323,291 @@ func lexArrayIndex(l *lex) stateFunction {
 	}
 	l.scanArrayIndex()
 	l.emit(tokenArrayIndex)
-	switch ch := l.read(); ch {
-	case ']':
+	if l.opts.indexRanges && l.peek() == ':' {
+		l.read()
+		l.emit(tokenArrayRangeSeparator)
+		return lexArrayRangeEnd
+	}
+	return lexArrayIndexFinish
+}
+
+// Lex the last index of an array index range.
+func lexArrayRangeEnd(l *lex) stateFunction {
+	switch ch := l.read(); {
+	case isArrayIndexChar(ch):
+	default:
+		return l.error("unexpected %v, expecting an array index", ch)
+	}
+	l.scanArrayIndex()
+	l.emit(tokenArrayIndex)
+	return lexArrayIndexFinish
+}
+
+// Lex the content of square brackets that is either an array index or,
+// if it is not numeric, a key.
+func lexBracketContent(l *lex) stateFunction {
+	numeric := true
+	finish := strRune(l.opts.syntax.IndexFinish)
+	for r := l.read(); r != end && r != finish; r = l.read() {
+		numeric = numeric && (isArrayIndexChar(r) || r == '-' && len(l.buffer) == 1)
+	}
+	l.unread()
+	numeric = numeric && string(l.buffer) != "-"
+	switch {
+	case len(l.buffer) == 0 && l.peek() == finish:
+		// An empty index appends to the array
+	case len(l.buffer) == 0:
+		return l.error("unexpected %v, expecting an array index", l.read())
+	case numeric:
+		l.emit(tokenArrayIndex)
+	default:
+		l.emit(tokenArrayKey)
+	}
+	return lexArrayIndexFinish
+}
+
+func lexArrayIndexFinish(l *lex) stateFunction {
+	finish := l.opts.syntax.IndexFinish
+	switch ch := l.read(); {
+	case rune(ch) == finish:
 		l.emit(tokenArrayIndexFinish)
 		return lexLeftValue
 	default:
-		return l.error("unexpected %v, expecting ']'", ch)
+		return l.error("unexpected %v, expecting '%c'", ch, finish)
 	}
 }
 
+// Lex a value up to the end of the input or a comma separating it from the
+// next assignment. Commas inside double quotes, square brackets or curly
+// braces belong to the value, and the other ones escaped with a backslash
+// are unescaped. The other escapes are interpreted outside the quotes,
+// brackets and braces, while the ones inside them are left to the parsing of
+// the value.
 func lexValue(l *lex) stateFunction {
-	var valueLength = 0
-	for r := l.read(); r != end; r = l.read() {
-		valueLength++
+	if l.peek() == '"' && lexesQuotes(l.opts) {
+		return lexQuotedValue
 	}
-	if valueLength > 0 {
-		l.unread()
+	escapes := lexesEscapes(l.opts) && !isRegexpValue(l)
+	depth := 0
+	quoted := false
+Loop:
+	for {
+		switch r := l.read(); {
+		case r == end:
+			break Loop
+		case r == '\\':
+			switch ch := l.peek(); {
+			case ch == ',' && depth == 0:
+				l.skipLast()
+				l.read()
+			case quoted || depth > 0 || !escapes:
+				if ch != end {
+					l.read()
+				}
+			case isEscapedPrefix(l):
+				l.read()
+			default:
+				if err := l.unescapeValue(); err != nil {
+					return l.error("%v", err)
+				}
+			}
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '#' && startsComment(l):
+			// The comment runs to the end of the input, commas included
+			for l.read() != end {
+			}
+			break Loop
+		case r == '[' || r == '{':
+			depth++
+		case (r == ']' || r == '}') && depth > 0:
+			depth--
+		case r == ',' && depth == 0 && !l.scalar:
+			l.unread()
+			break Loop
+		}
+	}
+	if len(l.buffer) > 0 {
 		l.emit(tokenValue)
+	}
+	return lexValueEnd
+}
+
+// Check whether the '#' just read starts a comment, which is the case if it
+// starts the value or follows a space with the comments recorded.
+func startsComment(l *lex) bool {
+	n := len(l.buffer)
+	return l.opts.comments != nil && (n == 1 || unicode.IsSpace(l.buffer[n-2]))
+}
+
+// Check whether double quoted values are lexed as strings, which is not the
+// case with the options handling the quotes of values themselves.
+func lexesQuotes(o options) bool {
+	return !o.goUnquote && !o.stripQuotes && o.comments == nil
+}
+
+// Check whether the escapes of values are interpreted, which is not the case
+// with the options handling the escapes of values themselves.
+func lexesEscapes(o options) bool {
+	return !o.goUnquote && !o.stripQuotes && len(o.shellSplitPaths) == 0
+}
+
+// Check whether the value is a regular expression, whose escapes are kept
+// for the compiling of the expression.
+func isRegexpValue(l *lex) bool {
+	return l.opts.regexpValues && strings.HasPrefix(l.input[l.position:], regexpPrefix)
+}
+
+// Check whether the backslash just read starts an escaped prefix of the
+// templates or the regular expressions, which is kept for the parsing of the
+// value.
+func isEscapedPrefix(l *lex) bool {
+	if len(l.buffer) != 1 {
+		return false
+	}
+	rest := l.input[l.position:]
+	return l.opts.templates && strings.HasPrefix(rest, templatePrefix) ||
+		l.opts.regexpValues && strings.HasPrefix(rest, regexpPrefix)
+}
+
+var valueEscapes = map[strRune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'\\': '\\',
+	'"':  '"',
+}
+
+// Unescape the character following the backslash just read. Apart from the
+// known escapes, the characters escapable in map keys can be escaped, while
+// the escapes of the characters interpreted by the options parsing the value
+// are kept, e.g. the escaped type sigils or value separators. Escaping any
+// other character is an error.
+func (l *lex) unescapeValue() error {
+	ch := l.peek()
+	switch {
+	case ch == end:
+		return fmt.Errorf("incomplete escape sequence: %v", ch)
+	case ch == 'u':
+		return l.unescapeUnicode()
+	case l.keepsEscape(ch):
+		l.read()
+		return nil
+	}
+	r, ok := valueEscapes[ch]
+	if !ok && (isStopChar(ch, l.stops) || isStopChar(ch, stopLeftValueChars) || ch == ']') {
+		r, ok = rune(ch), true
+	}
+	l.read()
+	if !ok {
+		return fmt.Errorf("unknown escape sequence: %v", ch)
+	}
+	// Replace the backslash and the escaped character
+	l.buffer = append(l.buffer[:len(l.buffer)-2], r)
+	return nil
+}
+
+// Check whether the escape of the character is kept for an option parsing
+// the value.
+func (l *lex) keepsEscape(ch strRune) bool {
+	return l.opts.valueSeparator != 0 && ch == strRune(l.opts.valueSeparator) ||
+		l.opts.valueSigils && len(l.buffer) == 1 && strings.ContainsRune("#~?$", rune(ch)) ||
+		l.opts.comments != nil && ch == '#'
+}
+
+// Unescape the unicode escape of four hex digits following the backslash
+// just read, combining the surrogate pairs of two consecutive escapes into
+// single runes.
+func (l *lex) unescapeUnicode() error {
+	start := len(l.buffer) - 1
+	l.read()
+	r, err := l.readHex()
+	if err != nil {
+		return err
+	}
+	if utf16.IsSurrogate(r) {
+		if r >= 0xDC00 || !strings.HasPrefix(l.input[l.position:], "\\u") {
+			return fmt.Errorf("unpaired surrogate in unicode escape sequence: %U", r)
+		}
+		l.read()
 		l.read()
+		low, err := l.readHex()
+		if err != nil {
+			return err
+		}
+		pair := utf16.DecodeRune(r, low)
+		if pair == unicode.ReplacementChar {
+			return fmt.Errorf("unpaired surrogate in unicode escape sequence: %U", r)
+		}
+		r = pair
 	}
-	l.emit(tokenEnd)
+	l.buffer = append(l.buffer[:start], r)
 	return nil
 }
 
+// Read the four hex digits of a unicode escape.
+func (l *lex) readHex() (rune, error) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		ch := l.read()
+		d := strings.IndexRune("0123456789abcdef", unicode.ToLower(rune(ch)))
+		switch {
+		case ch == end:
+			return 0, fmt.Errorf("incomplete escape sequence: %v", ch)
+		case d < 0:
+			return 0, fmt.Errorf("invalid unicode escape sequence: %v", ch)
+		}
+		r = r<<4 | rune(d)
+	}
+	return r, nil
+}
+
+// Lex a double quoted value up to the closing quote, unescaping the double
+// quotes along with the other escapes of values.
+func lexQuotedValue(l *lex) stateFunction {
+	l.read()
+	l.skipLast()
+	start := l.position
+	for {
+		switch r := l.read(); {
+		case r == end:
+			return l.error("unterminated quote in position %d", start)
+		case r == '\\':
+			switch ch := l.peek(); {
+			case ch == '"':
+				l.skipLast()
+				l.read()
+			case ch == end:
+			default:
+				if err := l.unescapeValue(); err != nil {
+					return l.error("%v", err)
+				}
+			}
+		case r == '"':
+			l.skipLast()
+			l.emit(tokenQuotedValue)
+			return lexValueEnd
+		}
+	}
+}
+
+// Lex the end of an assignment, which is either the end of the input or a
+// comma separating it from the next assignment. Anything else can only
+// follow a closing quote, which fails the lexing.
+func lexValueEnd(l *lex) stateFunction {
+	switch r := l.read(); r {
+	case end:
+		l.emit(tokenEnd)
+		return nil
+	case ',':
+		l.emit(tokenAssignmentSeparator)
+		return lexRootKey
+	default:
+		return l.error("unexpected %v, expecting ',' after the closing quote", r)
+	}
+}
+
 func (l *lex) scan(stopCharSet map[strRune]bool) error {
 Loop:
 	for {
@@ -236,9 +616,16 @@ Loop:
 			break Loop
 		case r == '\\':
 			switch ch := l.peek(); {
-			case isStopChar(ch, stopCharSet) || ch == '\\':
+			case ch == end:
+				return fmt.Errorf("incomplete escape sequence: %v", ch)
+			case isStopChar(ch, stopCharSet) || isStopChar(ch, stopLeftValueChars) ||
+				ch == '\\' || ch == '!' && l.opts.bareKeyBooleans:
 				l.skipLast()
 				l.read()
+			case ch == 'u':
+				if err := l.unescapeUnicode(); err != nil {
+					return err
+				}
 			default:
 				l.read()
 				return fmt.Errorf("unknown escape sequence: %v", ch)
@@ -269,6 +656,7 @@ var (
 		'=': true,
 		'.': true,
 		'[': true,
+		',': true,
 	}
 )
 
@@ -280,3 +668,42 @@ func isStopChar(r strRune, stopCharSet map[strRune]bool) bool {
 func isArrayIndexChar(r strRune) bool {
 	return unicode.IsNumber(rune(r))
 }
+
+// Check that the unescaped square brackets and double quotes of the input are
+// balanced, reporting the position of the offending one otherwise. Square
+// brackets inside quotes are not taken into account.
+func checkBalance(input string) error {
+	var brackets []int
+	quote := -1
+	escaped := false
+	for i, r := range input {
+		position := i + 1
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			if quote < 0 {
+				quote = position
+			} else {
+				quote = -1
+			}
+		case quote >= 0:
+		case r == '[':
+			brackets = append(brackets, position)
+		case r == ']':
+			if len(brackets) == 0 {
+				return fmt.Errorf("unbalanced ']' in position %d", position)
+			}
+			brackets = brackets[:len(brackets)-1]
+		}
+	}
+	if quote >= 0 {
+		return fmt.Errorf("unbalanced '\"' in position %d", quote)
+	}
+	if len(brackets) > 0 {
+		return fmt.Errorf("unbalanced '[' in position %d", brackets[len(brackets)-1])
+	}
+	return nil
+}
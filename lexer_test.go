@@ -2,7 +2,9 @@ package djson
 
 import (
 	"reflect"
+	"runtime"
 	"testing"
+	"time"
 )
 
 type lexTestCase struct {
@@ -113,6 +115,34 @@ func Test_Lex_Value_Succeeds(t *testing.T) {
 				newToken(tokenValue, 13, "v"),
 				newToken(tokenEnd, 14, ""),
 			}),
+		newTestCase("escapes in a value", "k=a\\nb\\tc\\rd\\\\",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenValue, 2, "a\nb\tc\rd\\"),
+				newToken(tokenEnd, 14, ""),
+			}),
+		newTestCase("escapes in brackets and quotes", "k=[a\\n]\"\\t\"",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenValue, 2, "[a\\n]\"\\t\""),
+				newToken(tokenEnd, 11, ""),
+			}),
+		newTestCase("unicode escapes in a key and a value", "caf\\u00e9=\\u00C9t\\ud83d\\ude00",
+			[]token{
+				newToken(tokenMapKey, 0, "caf\u00e9"),
+				newToken(tokenAssignment, 9, "="),
+				newToken(tokenValue, 10, "\u00c9t\U0001f600"),
+				newToken(tokenEnd, 29, ""),
+			}),
+		newTestCase("an escaped stop character in a value", "k=\\=1",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenValue, 2, "=1"),
+				newToken(tokenEnd, 5, ""),
+			}),
 	}
 	for _, test := range testCases {
 		result := testLex(test.input)
@@ -181,6 +211,55 @@ func Test_Lex_Fails(t *testing.T) {
 			[]token{
 				newToken(tokenError, 0, "in position 7 got unknown escape sequence: character: U+002D '-'"),
 			}),
+		newTestCase("a trailing backslash in a key", "foo\\",
+			[]token{
+				newToken(tokenError, 0, "incomplete escape sequence: end"),
+			}),
+		newTestCase("escaping unescapable in a value", "k=a\\q",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenError, 2, "in position 5 got unknown escape sequence: character: U+0071 'q'"),
+			}),
+		newTestCase("a trailing backslash in a value", "k=a\\",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenError, 2, "incomplete escape sequence: end"),
+			}),
+		newTestCase("an invalid unicode escape in a key", "k\\u00g0=v",
+			[]token{
+				newToken(tokenError, 0, "in position 6 got invalid unicode escape sequence: character: U+0067 'g'"),
+			}),
+		newTestCase("an invalid unicode escape in a value", "k=\\u00g0",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenError, 2, "in position 7 got invalid unicode escape sequence: character: U+0067 'g'"),
+			}),
+		newTestCase("a truncated unicode escape", "k=\\u00",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenError, 2, "incomplete escape sequence: end"),
+			}),
+		newTestCase("an unpaired surrogate", "k=\\ud83dx",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenError, 2, "in position 8 got unpaired surrogate in unicode escape sequence: U+D83D"),
+			}),
+		newTestCase("a surrogate paired with a non-surrogate", "k=\\ud83d\\u0041",
+			[]token{
+				newToken(tokenMapKey, 0, "k"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenError, 2, "in position 14 got unpaired surrogate in unicode escape sequence: U+D83D"),
+			}),
+		newTestCase("an escaped backslash at the end of a key", "foo\\\\",
+			[]token{
+				newToken(tokenMapKey, 0, "foo\\"),
+				newToken(tokenError, 5, "unexpected end, expecting '.', '=' or '['"),
+			}),
 	}
 
 	for _, test := range testCases {
@@ -192,8 +271,12 @@ func Test_Lex_Fails(t *testing.T) {
 	}
 }
 
-func testLex(input string) (tokens []token) {
-	lex := newLex(input)
+func testLex(input string) []token {
+	return testLexWithOptions(input, options{})
+}
+
+func testLexWithOptions(input string, opts options) (tokens []token) {
+	lex := newLex(input, opts)
 	for {
 		tok := lex.nextToken()
 		tokens = append(tokens, tok)
@@ -204,16 +287,81 @@ func testLex(input string) (tokens []token) {
 	return
 }
 
-func Test_Lex_Drain(t *testing.T) {
-	lex := &lex{
-		input:  "foo=bar",
-		tokens: make(chan token),
+func Test_Lex_Bracket_Keys(t *testing.T) {
+	testCases := []lexTestCase{
+		newTestCase("a duration key", "key[1h]=v",
+			[]token{
+				newToken(tokenMapKey, 0, "key"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayKey, 4, "1h"),
+				newToken(tokenArrayIndexFinish, 6, "]"),
+				newToken(tokenAssignment, 7, "="),
+				newToken(tokenValue, 8, "v"),
+				newToken(tokenEnd, 9, ""),
+			}),
+		newTestCase("a numeric index", "key[10]=v",
+			[]token{
+				newToken(tokenMapKey, 0, "key"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayIndex, 4, "10"),
+				newToken(tokenArrayIndexFinish, 6, "]"),
+				newToken(tokenAssignment, 7, "="),
+				newToken(tokenValue, 8, "v"),
+				newToken(tokenEnd, 9, ""),
+			}),
+		newTestCase("a negative index", "key[-1]=v",
+			[]token{
+				newToken(tokenMapKey, 0, "key"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayIndex, 4, "-1"),
+				newToken(tokenArrayIndexFinish, 6, "]"),
+				newToken(tokenAssignment, 7, "="),
+				newToken(tokenValue, 8, "v"),
+				newToken(tokenEnd, 9, ""),
+			}),
+		newTestCase("a negative duration key", "key[-1h]=v",
+			[]token{
+				newToken(tokenMapKey, 0, "key"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayKey, 4, "-1h"),
+				newToken(tokenArrayIndexFinish, 7, "]"),
+				newToken(tokenAssignment, 8, "="),
+				newToken(tokenValue, 9, "v"),
+				newToken(tokenEnd, 10, ""),
+			}),
+		newTestCase("a sign", "key[-]=v",
+			[]token{
+				newToken(tokenMapKey, 0, "key"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayKey, 4, "-"),
+				newToken(tokenArrayIndexFinish, 5, "]"),
+				newToken(tokenAssignment, 6, "="),
+				newToken(tokenValue, 7, "v"),
+				newToken(tokenEnd, 8, ""),
+			}),
+		newTestCase("an empty content", "key[]=v",
+			[]token{
+				newToken(tokenMapKey, 0, "key"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayIndexFinish, 4, "]"),
+				newToken(tokenAssignment, 5, "="),
+				newToken(tokenValue, 6, "v"),
+				newToken(tokenEnd, 7, ""),
+			}),
+		newTestCase("an unfinished content", "key[1h",
+			[]token{
+				newToken(tokenMapKey, 0, "key"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayKey, 4, "1h"),
+				newToken(tokenError, 6, "unexpected end, expecting ']'"),
+			}),
 	}
-	go lex.run()
-	lex.drain()
-	for range lex.tokens {
-		t.Errorf("lex.drain does not drain the tokens")
-		break
+	for _, test := range testCases {
+		result := testLexWithOptions(test.input, options{durationKeys: true})
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v",
+				test.desc, test.input, test.expected, result)
+		}
 	}
 }
 
@@ -235,3 +383,201 @@ func Test_tokenType_String(t *testing.T) {
 		t.Errorf("Expected string %s for a token with no string, got %s", tokenStrings[tokenUnknown], str)
 	}
 }
+
+func Test_Lex_Rejects_Invalid_UTF8(t *testing.T) {
+	testCases := []lexTestCase{
+		newTestCase("an invalid byte in a value", "a=b\xffc",
+			[]token{
+				newToken(tokenError, 3, "in position 4 got an invalid UTF-8 sequence"),
+			}),
+		newTestCase("a truncated sequence in a key", "k\xe2\x82=v",
+			[]token{
+				newToken(tokenError, 1, "in position 2 got an invalid UTF-8 sequence"),
+			}),
+		newTestCase("valid multibyte characters", "ключ=�значение",
+			[]token{
+				newToken(tokenMapKey, 0, "ключ"),
+				newToken(tokenAssignment, 8, "="),
+				newToken(tokenValue, 9, "�значение"),
+				newToken(tokenEnd, 28, ""),
+			}),
+	}
+	for _, test := range testCases {
+		result := testLexWithOptions(test.input, options{rejectInvalidUTF8: true})
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v",
+				test.desc, test.input, test.expected, result)
+		}
+	}
+}
+
+func Test_Lex_Index_Ranges(t *testing.T) {
+	testCases := []lexTestCase{
+		newTestCase("an index range", "foo[0:12]=x",
+			[]token{
+				newToken(tokenMapKey, 0, "foo"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayIndex, 4, "0"),
+				newToken(tokenArrayRangeSeparator, 5, ":"),
+				newToken(tokenArrayIndex, 6, "12"),
+				newToken(tokenArrayIndexFinish, 8, "]"),
+				newToken(tokenAssignment, 9, "="),
+				newToken(tokenValue, 10, "x"),
+				newToken(tokenEnd, 11, ""),
+			}),
+		newTestCase("a single index", "foo[1]=x",
+			[]token{
+				newToken(tokenMapKey, 0, "foo"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayIndex, 4, "1"),
+				newToken(tokenArrayIndexFinish, 5, "]"),
+				newToken(tokenAssignment, 6, "="),
+				newToken(tokenValue, 7, "x"),
+				newToken(tokenEnd, 8, ""),
+			}),
+		newTestCase("a range without the last index", "foo[0:]=x",
+			[]token{
+				newToken(tokenMapKey, 0, "foo"),
+				newToken(tokenArrayIndexStart, 3, "["),
+				newToken(tokenArrayIndex, 4, "0"),
+				newToken(tokenArrayRangeSeparator, 5, ":"),
+				newToken(tokenError, 6, "in position 7 got unexpected character: U+005D ']', expecting an array index"),
+			}),
+	}
+	for _, test := range testCases {
+		result := testLexWithOptions(test.input, options{indexRanges: true})
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v",
+				test.desc, test.input, test.expected, result)
+		}
+	}
+}
+
+func Test_Lex_Bare_Keys(t *testing.T) {
+	testCases := []lexTestCase{
+		newTestCase("a bare key", "foo.bar",
+			[]token{
+				newToken(tokenMapKey, 0, "foo"),
+				newToken(tokenMapKeySeparator, 3, "."),
+				newToken(tokenMapKey, 4, "bar"),
+				newToken(tokenEnd, 7, ""),
+			}),
+		newTestCase("a negated bare key", "!foo",
+			[]token{
+				newToken(tokenNegation, 0, "!"),
+				newToken(tokenMapKey, 1, "foo"),
+				newToken(tokenEnd, 4, ""),
+			}),
+		newTestCase("an escaped negation", "\\!foo=x",
+			[]token{
+				newToken(tokenMapKey, 0, "!foo"),
+				newToken(tokenAssignment, 5, "="),
+				newToken(tokenValue, 6, "x"),
+				newToken(tokenEnd, 7, ""),
+			}),
+	}
+	for _, test := range testCases {
+		result := testLexWithOptions(test.input, options{bareKeyBooleans: true})
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v",
+				test.desc, test.input, test.expected, result)
+		}
+	}
+}
+
+func Test_Lex_Close_Ends_Abandoned_Lexing(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		lex := newLex("foo.bar[0].baz=qux", options{})
+		lex.nextToken()
+		lex.close()
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("Expected %d goroutines after closing the lexers, got %d", before, n)
+	}
+}
+
+func Test_Lex_Assignment_Separators(t *testing.T) {
+	testCases := []lexTestCase{
+		newTestCase("two assignments", "a=x,b=y",
+			[]token{
+				newToken(tokenMapKey, 0, "a"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenValue, 2, "x"),
+				newToken(tokenAssignmentSeparator, 3, ","),
+				newToken(tokenMapKey, 4, "b"),
+				newToken(tokenAssignment, 5, "="),
+				newToken(tokenValue, 6, "y"),
+				newToken(tokenEnd, 7, ""),
+			}),
+		newTestCase("an empty value", "a=,b=y",
+			[]token{
+				newToken(tokenMapKey, 0, "a"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenAssignmentSeparator, 2, ","),
+				newToken(tokenMapKey, 3, "b"),
+				newToken(tokenAssignment, 4, "="),
+				newToken(tokenValue, 5, "y"),
+				newToken(tokenEnd, 6, ""),
+			}),
+		newTestCase("an escaped comma", "a=x\\,y",
+			[]token{
+				newToken(tokenMapKey, 0, "a"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenValue, 2, "x,y"),
+				newToken(tokenEnd, 6, ""),
+			}),
+		newTestCase("commas in brackets, braces and quotes", "a=[1,2],b={c=1,d=2},e=\"f,g\"",
+			[]token{
+				newToken(tokenMapKey, 0, "a"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenValue, 2, "[1,2]"),
+				newToken(tokenAssignmentSeparator, 7, ","),
+				newToken(tokenMapKey, 8, "b"),
+				newToken(tokenAssignment, 9, "="),
+				newToken(tokenValue, 10, "{c=1,d=2}"),
+				newToken(tokenAssignmentSeparator, 19, ","),
+				newToken(tokenMapKey, 20, "e"),
+				newToken(tokenAssignment, 21, "="),
+				newToken(tokenQuotedValue, 22, "f,g"),
+				newToken(tokenEnd, 27, ""),
+			}),
+		newTestCase("deletions", "a-,b[0]-,c-d-",
+			[]token{
+				newToken(tokenMapKey, 0, "a"),
+				newToken(tokenDeletion, 1, "-"),
+				newToken(tokenAssignmentSeparator, 2, ","),
+				newToken(tokenMapKey, 3, "b"),
+				newToken(tokenArrayIndexStart, 4, "["),
+				newToken(tokenArrayIndex, 5, "0"),
+				newToken(tokenArrayIndexFinish, 6, "]"),
+				newToken(tokenDeletion, 7, "-"),
+				newToken(tokenAssignmentSeparator, 8, ","),
+				newToken(tokenMapKey, 9, "c-d"),
+				newToken(tokenDeletion, 12, "-"),
+				newToken(tokenEnd, 13, ""),
+			}),
+		newTestCase("other escapes in a value", "a=\\\\,b=\\.",
+			[]token{
+				newToken(tokenMapKey, 0, "a"),
+				newToken(tokenAssignment, 1, "="),
+				newToken(tokenValue, 2, "\\"),
+				newToken(tokenAssignmentSeparator, 4, ","),
+				newToken(tokenMapKey, 5, "b"),
+				newToken(tokenAssignment, 6, "="),
+				newToken(tokenValue, 7, "."),
+				newToken(tokenEnd, 9, ""),
+			}),
+	}
+	for _, test := range testCases {
+		result := testLexWithOptions(test.input, options{})
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v",
+				test.desc, test.input, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,857 @@
+package djson
+
+import (
+	"io/fs"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Option configures the way an input string is parsed and merged.
+type Option func(*options)
+
+type options struct {
+	templates    bool        // Render "tpl:" prefixed values
+	templateData interface{} // The data context for rendering templates
+
+	assignmentOrder map[string][]int // Array indices in the order of assignment
+	assignedPaths   *[]string        // Paths of the assignments in their order
+	topKeys         *[]string        // Top-level keys of the assignments in their order
+
+	comments map[string]string      // Comments following values per path
+	anchors  map[string]interface{} // Anchored values per anchor name
+
+	valueSeparator rune // Splits values into arrays, zero if disabled
+
+	matrixValues  bool // Parse bracketed values as nested arrays
+	references    bool // Parse "@ref:" prefixed values as references
+	nestedValues  bool // Parse "nested:" prefixed values as expressions
+	orderedValues bool // Parse braced values as ordered maps
+
+	rejectSpaces bool // Reject unquoted values containing spaces
+	goUnquote    bool // Unquote values quoted following the Go syntax
+	stripQuotes  bool // Strip matching quotes surrounding values
+	ipParsing    bool // Convert values to IP addresses and networks
+	uuidParsing  bool // Convert values to UUIDs
+
+	patternParsers []PatternParser // Convert values matching patterns
+	literals       *LiteralsTable  // Boolean and null literals
+
+	unionParsers map[string]UnionParser // Parsers of values per prefix
+
+	emptySentinel *string // The value standing for an empty string
+	nullSentinel  *string // The value standing for a null value
+
+	multiValuePaths map[string]bool // Paths collecting ordered key-value pairs
+
+	globFS    fs.FS           // The file system to expand globs against
+	globPaths map[string]bool // Paths holding globs to expand
+
+	basePath  string          // The directory relative file paths are resolved against
+	filePaths map[string]bool // Paths holding file paths to resolve
+
+	shellSplitPaths map[string]bool // Paths holding shell words to split
+
+	quantityParsing bool            // Convert quantities with suffixes to numbers
+	byteSizeParsing bool            // Convert byte sizes with suffixes to numbers of bytes
+	byteSizeBinary  bool            // Take decimal byte size suffixes for binary ones
+	byteSizePaths   map[string]bool // Paths holding byte sizes, nil for all the paths
+	unitSplitting   bool            // Split numbers with units into Quantity
+	valueSigils     bool            // Force the types of values with leading sigils
+	regexpValues    bool            // Compile "re:" prefixed values as regular expressions
+	complexParsing  bool            // Convert values to complex numbers
+
+	numericRanges map[string][2]float64 // Inclusive bounds of numbers per path
+
+	conflictResolver  ConflictResolver // Resolves overwriting existing values
+	homogeneousArrays bool             // Reject array elements of different types
+	preserveTypes     bool             // Reject changing kinds of existing values
+	safeConversions   bool             // Convert values to existing kinds first
+
+	coercionWarnings *[]CoercionWarning // Collects lossy conversions of numbers
+
+	stringNormalizer func(string) string // Normalizes string values
+	foldLines        bool                // Fold the lines of multi-line values to spaces
+	stripIndent      bool                // Strip the common indentation of multi-line values
+
+	converters   []Converter  // Replaces the default coercion of values
+	boolDetector BoolDetector // Detects booleans before any coercion
+
+	trimTrailingNils bool // Remove trailing nil elements of arrays
+	sparseArrays     bool // Build arrays with scattered indices as SparseArray
+
+	maxDepth   int // The maximum depth of a path
+	exactDepth int // The required depth of a path
+	maxKeys    int // The maximum number of map keys created
+	maxGapFill int // The maximum number of array gaps filled with nil
+	maxIndex   int // The maximum array index, zero if unlimited
+
+	plusAsSpace bool // Decode '+' in query strings to a space
+
+	rejectInvalidUTF8 bool // Reject input which is not valid UTF-8
+
+	bareKeyBooleans  bool           // Accept bare keys standing for booleans
+	bareWordFallback bool           // Accept inputs which are not paths
+	fallbackKey      string         // The key assigned inputs which are not paths
+	indexNames       map[string]int // Names standing for array indices
+	indexRanges      bool           // Accept array index ranges like "[0:2]"
+
+	durationKeys bool // Treat non-numeric square brackets as duration keys
+	balanceCheck bool // Check the balance of brackets and quotes first
+
+	syntax Syntax // The characters of the path syntax
+
+	repeatedKeysAsList bool // Collect repeated assignments into lists
+
+	schema        Schema // The types of the values per path
+	numericBools  bool   // Coerce any numbers at boolean paths
+	tristateBools bool   // Coerce values at boolean paths to *bool
+
+	timeLayouts   map[string]string // The layouts of the times per path
+	unixTimeUnit  time.Duration     // The unit of Unix times
+	unixTimePaths map[string]bool   // Paths holding Unix times
+	versionPaths  map[string]bool   // Paths holding version constraints
+}
+
+// ConflictResolver is called when a value is assigned to a leaf that already
+// holds a non-nil value. It receives the path of the leaf, the existing value
+// and the new one, and returns the value to store.
+type ConflictResolver func(path string, old, new interface{}) interface{}
+
+// PatternParser converts the values matching the pattern. Convert receives
+// the match and the submatches of the capture groups as returned by
+// regexp.FindStringSubmatch, and returns the value to store.
+type PatternParser struct {
+	Pattern *regexp.Regexp
+	Convert func(match []string) interface{}
+}
+
+// UnionParser converts a value with its prefix stripped.
+type UnionParser func(val string) (interface{}, error)
+
+// KeyValue is a key-value pair collected at a multi-value path.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// The maximum array index unless changed with WithMaxIndex.
+const defaultMaxIndex = 10000
+
+func newOptions(opts []Option) options {
+	o := options{
+		maxIndex: defaultMaxIndex,
+		syntax:   defaultSyntax,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithTemplateData enables rendering of values prefixed with "tpl:" as
+// text/template templates executed against the data provided. For example,
+// "key=tpl:{{ .Name }}" renders the template and stores the result. The prefix
+// can be escaped as "\tpl:" for keeping the value literal.
+func WithTemplateData(data interface{}) Option {
+	return func(o *options) {
+		o.templates = true
+		o.templateData = data
+	}
+}
+
+// WithAssignmentOrder records the array indices in the order they are
+// assigned. The indices are appended to the map provided under the path of
+// the array, e.g. "foo[2]=c" appends 2 to the indices under "foo". Recording
+// does not change the arrays being built.
+func WithAssignmentOrder(order map[string][]int) Option {
+	return func(o *options) {
+		o.assignmentOrder = order
+	}
+}
+
+// WithRejectSpacesInValues makes the parsing fail when an unquoted value
+// contains whitespace, e.g. "name=John Smith". Values enclosed in double
+// quotes are exempt, so that deliberate spaces can still be used.
+func WithRejectSpacesInValues() Option {
+	return func(o *options) {
+		o.rejectSpaces = true
+	}
+}
+
+// WithIPParsing converts the values that are IP addresses to net.IP and the
+// values in CIDR notation to *net.IPNet. The conversion is tried after the
+// numeric ones, so that numbers are never taken for addresses.
+func WithIPParsing() Option {
+	return func(o *options) {
+		o.ipParsing = true
+	}
+}
+
+// WithConflictResolver calls the resolver provided whenever a value is about
+// to overwrite a non-nil value, and stores the value the resolver returns.
+// Returning the old value keeps it, returning the new one overwrites it as
+// usual.
+func WithConflictResolver(resolver ConflictResolver) Option {
+	return func(o *options) {
+		o.conflictResolver = resolver
+	}
+}
+
+// WithMaxDepth limits the depth of the assignment paths, counting every map
+// key and array index, e.g. "foo[0].bar=baz" has depth 3. Structures already
+// present in the map are traversed only along the path, so their depth is
+// bounded by the limit as well.
+func WithMaxDepth(depth int) Option {
+	return func(o *options) {
+		o.maxDepth = depth
+	}
+}
+
+// WithExactDepth requires the assignment paths to have exactly the depth
+// provided, counted as with WithMaxDepth, failing the parsing of both
+// shallower and deeper paths.
+func WithExactDepth(depth int) Option {
+	return func(o *options) {
+		o.exactDepth = depth
+	}
+}
+
+// WithUUIDParsing converts the values that are UUIDs in the canonical form,
+// e.g. "550e8400-e29b-41d4-a716-446655440000", to UUID. The conversion is
+// tried after the numeric ones.
+func WithUUIDParsing() Option {
+	return func(o *options) {
+		o.uuidParsing = true
+	}
+}
+
+// WithQuantityParsing converts the numbers followed by SI or IEC suffixes to
+// their values in base units following the Kubernetes resource.Quantity
+// semantics, e.g. "2Mi" becomes 2097152, "1K" becomes 1000 and "500m"
+// becomes 0.5. The result is int64 when it is exact and float64 otherwise.
+// Values with unknown suffixes remain strings.
+func WithQuantityParsing() Option {
+	return func(o *options) {
+		o.quantityParsing = true
+	}
+}
+
+// WithByteSizeParsing converts the numbers followed by byte size suffixes at
+// the paths provided, or at all the paths if none are provided, to int64
+// numbers of bytes, e.g. "disk=10GB" stores int64(10000000000) and
+// "disk=10GiB" stores int64(10737418240). The decimal suffixes like "GB"
+// stand for powers of 1024 instead of 1000 if binary is set. Values with
+// unknown suffixes remain strings.
+func WithByteSizeParsing(binary bool, paths ...string) Option {
+	return func(o *options) {
+		o.byteSizeParsing = true
+		o.byteSizeBinary = binary
+		o.byteSizePaths = nil
+		if len(paths) > 0 {
+			o.byteSizePaths = make(map[string]bool, len(paths))
+			for _, path := range paths {
+				o.byteSizePaths[path] = true
+			}
+		}
+	}
+}
+
+// WithNumericRanges restricts the values at the paths provided to numbers
+// within the inclusive bounds [min, max], e.g. {"port": {1, 65535}} rejects
+// "port=70000". A value that is not a number at a restricted path fails the
+// parsing as well. The paths are written in the DJSON syntax, e.g.
+// "servers[0].port". The ranges are checked after the values are converted,
+// so they apply to MergeValue only.
+func WithNumericRanges(ranges map[string][2]float64) Option {
+	return func(o *options) {
+		o.numericRanges = ranges
+	}
+}
+
+// WithRepeatedKeysAsList collects the values assigned to the same map key
+// into a list instead of overwriting the existing one, e.g. merging "tag=a"
+// and "tag=b" produces ["a", "b"], and another "tag=c" appends "c" to it. A
+// repeated assignment to a key holding an array, however it was built,
+// appends to the array, while a repeated assignment to a key holding a map
+// fails. Null values and array elements, like "tag[0]=x", are overwritten as
+// usual.
+func WithRepeatedKeysAsList() Option {
+	return func(o *options) {
+		o.repeatedKeysAsList = true
+	}
+}
+
+// WithSchema coerces the values at the paths declared in the schema to the
+// declared types, failing the parsing if a value cannot be coerced. The values
+// at the other paths are converted as usual. The schema applies to MergeValue
+// only.
+func WithSchema(schema Schema) Option {
+	return func(o *options) {
+		o.schema = schema
+	}
+}
+
+// WithComplexParsing converts the values that are complex numbers, e.g.
+// "1+2i" or "3i", to complex128. The conversion is tried after the real
+// numbers, which keep their usual types.
+func WithComplexParsing() Option {
+	return func(o *options) {
+		o.complexParsing = true
+	}
+}
+
+// WithMaxKeys limits the number of distinct map keys a single merge can
+// create, e.g. "a.b.c=1" creates three keys when merged into an empty map.
+// The keys already present in the map are not counted.
+func WithMaxKeys(n int) Option {
+	return func(o *options) {
+		o.maxKeys = n
+	}
+}
+
+// WithPatternParsers converts the values matching the patterns of the parsers
+// provided. The parsers are tried in order after all the other conversions,
+// the first matching one produces the value, and the values matching none of
+// them remain strings. Anchor the patterns for matching whole values.
+func WithPatternParsers(parsers ...PatternParser) Option {
+	return func(o *options) {
+		o.patternParsers = parsers
+	}
+}
+
+// WithSyntax replaces the characters of the path syntax with the ones
+// declared, e.g. with Syntax{KeySeparator: '/'} "a.b/c=1" stores 1 under the
+// key "c" of the map under the key "a.b". The default characters stay
+// escapable with a backslash, and the characters must be distinct, and
+// neither ',', '\\', '-' nor ':', or the parsing fails. The paths of the
+// other options keep the default syntax.
+func WithSyntax(syntax Syntax) Option {
+	return func(o *options) {
+		o.syntax = syntax.withDefaults()
+	}
+}
+
+// WithLiterals replaces the English boolean and null literals, e.g. "true"
+// and "null", with the ones of the table provided. Only the literals of the
+// table are recognized, so include the English ones from DefaultLiterals to
+// keep them recognized as well.
+func WithLiterals(literals LiteralsTable) Option {
+	return func(o *options) {
+		o.literals = &literals
+	}
+}
+
+// WithGoUnquote unquotes the values that begin and end with matching double
+// quotes, single quotes or backticks following the Go syntax of quoted
+// literals, e.g. "key=\"a\\tb\"" stores "a<tab>b". The unquoted values are
+// stored as strings, and the values that cannot be unquoted fail the parsing.
+func WithGoUnquote() Option {
+	return func(o *options) {
+		o.goUnquote = true
+	}
+}
+
+// WithDurationKeys treats non-numeric content of square brackets as a
+// duration keying a map instead of an array index, e.g.
+// "series[1h]=x,series[30m]=y" produces a map with keys "1h0m0s" and
+// "30m0s". The keys are the durations in the canonical form returned by
+// time.Duration.String, so equal durations written differently share a key.
+// Numeric content of square brackets remains an array index.
+func WithDurationKeys() Option {
+	return func(o *options) {
+		o.durationKeys = true
+	}
+}
+
+// WithUnionParsers dispatches the values starting with the prefixes provided
+// to the parsers registered for them, e.g. with a parser for "worker:" the
+// value "worker:node1" is converted by that parser receiving "node1". The
+// longest matching prefix wins, a parser error fails the parsing, and the
+// values with no matching prefix are converted as usual.
+func WithUnionParsers(parsers map[string]UnionParser) Option {
+	return func(o *options) {
+		o.unionParsers = parsers
+	}
+}
+
+// WithBalanceCheck checks that the unescaped square brackets and double
+// quotes of the whole input are balanced before parsing it, and reports a
+// single error with the position of the offending bracket or quote instead
+// of the lower-level parsing errors. Square brackets inside quotes are not
+// taken into account.
+func WithBalanceCheck() Option {
+	return func(o *options) {
+		o.balanceCheck = true
+	}
+}
+
+// WithEmptySentinel makes the value provided, e.g. "_empty_", stand for an
+// empty string, for the generators that cannot emit empty values. The
+// sentinel applies to MergeValue only.
+func WithEmptySentinel(sentinel string) Option {
+	return func(o *options) {
+		o.emptySentinel = &sentinel
+	}
+}
+
+// WithNullSentinel makes the value provided, e.g. "_null_", stand for a null
+// value in addition to the null literals. The sentinel applies to MergeValue
+// only.
+func WithNullSentinel(sentinel string) Option {
+	return func(o *options) {
+		o.nullSentinel = &sentinel
+	}
+}
+
+// WithMultiValuePaths collects the assignments to the keys directly under
+// the paths provided into ordered lists of key-value pairs instead of maps,
+// so that repeated keys are preserved, e.g. with the path "header" merging
+// "header.Set=a" and "header.Set=b" stores []KeyValue{{"Set", "a"}, {"Set",
+// "b"}} under "header". Only direct assignments are accepted under the paths.
+func WithMultiValuePaths(paths ...string) Option {
+	return func(o *options) {
+		o.multiValuePaths = map[string]bool{}
+		for _, path := range paths {
+			o.multiValuePaths[path] = true
+		}
+	}
+}
+
+// WithGlobPaths expands the values at the paths provided as glob patterns
+// against the file system, e.g. "files=*.yaml" stores the array of the
+// matching file names in lexical order under "files". A pattern matching no
+// files produces an empty array, and an invalid pattern fails the parsing.
+// The expansion applies to MergeValue only.
+func WithGlobPaths(fsys fs.FS, paths ...string) Option {
+	return func(o *options) {
+		o.globFS = fsys
+		o.globPaths = map[string]bool{}
+		for _, path := range paths {
+			o.globPaths[path] = true
+		}
+	}
+}
+
+// WithBasePath resolves the values at the paths provided as file paths
+// relative to the base directory, joining them with the directory and
+// cleaning them, e.g. "config=./sub/../x.yaml" stores "/etc/app/x.yaml" with
+// the base "/etc/app". Absolute file paths are left as they are. The
+// resolution applies to MergeValue only.
+func WithBasePath(dir string, paths ...string) Option {
+	return func(o *options) {
+		o.basePath = dir
+		o.filePaths = map[string]bool{}
+		for _, path := range paths {
+			o.filePaths[path] = true
+		}
+	}
+}
+
+// WithStripQuotes strips the matching double or single quotes surrounding
+// values, e.g. "name=\"bob\"" stores "bob". The content inside the quotes is
+// stored literally as a string with no conversion, and the values with
+// unmatched quotes are kept as they are.
+func WithStripQuotes() Option {
+	return func(o *options) {
+		o.stripQuotes = true
+	}
+}
+
+// WithMaxGapFill limits the total number of nil elements a single merge can
+// create filling the gaps before the array indices being assigned, e.g.
+// "a[5]=x" fills five gaps when merged into an empty map and
+// "a[3][3]=x" fills six.
+func WithMaxGapFill(n int) Option {
+	return func(o *options) {
+		o.maxGapFill = n
+	}
+}
+
+// WithMaxIndex limits the array indices of the assignment paths, 10000 by
+// default, so that an index like "a[1000000000]" cannot make the merge
+// allocate a huge array. Zero or a negative limit removes it. Single indices
+// of sparse arrays are not limited, as the elements before them are not
+// allocated.
+func WithMaxIndex(n int) Option {
+	return func(o *options) {
+		o.maxIndex = n
+	}
+}
+
+// Converter converts a value returning false if the value is not of its type.
+type Converter func(string) (interface{}, bool)
+
+// DefaultConverters returns the chain of converters reproducing the default
+// coercion of values to booleans, integers, floats and null.
+func DefaultConverters() []Converter {
+	return []Converter{
+		func(val string) (interface{}, bool) {
+			return defaultLiterals.parseBool(val)
+		},
+		func(val string) (interface{}, bool) {
+			i, err := strconv.ParseInt(val, 10, 64)
+			return i, err == nil
+		},
+		func(val string) (interface{}, bool) {
+			f, err := strconv.ParseFloat(val, 64)
+			return f, err == nil
+		},
+		func(val string) (interface{}, bool) {
+			return nil, defaultLiterals.isNull(val)
+		},
+	}
+}
+
+// WithConverterChain replaces the default coercion of values with the
+// converters provided, tried in sequence until one of them succeeds. Values
+// no converter accepts are kept as strings. The chain can extend the default
+// one, e.g. append(DefaultConverters(), myConverter).
+func WithConverterChain(converters ...Converter) Option {
+	return func(o *options) {
+		o.converters = converters
+	}
+}
+
+// WithSparseArrays builds arrays as SparseArray maps of their indices to
+// elements instead of filling the gaps between the indices with nil. Arrays
+// whose indices leave no gaps are still stored as []interface{}.
+func WithSparseArrays() Option {
+	return func(o *options) {
+		o.sparseArrays = true
+	}
+}
+
+// BoolDetector decides whether a value is a boolean, returning false as the
+// second result if it is not.
+type BoolDetector func(string) (bool, bool)
+
+// WithBoolDetector detects booleans with the function provided before
+// coercing values to any other type. Values the detector declines are
+// coerced as usual.
+func WithBoolDetector(detector BoolDetector) Option {
+	return func(o *options) {
+		o.boolDetector = detector
+	}
+}
+
+// WithRejectInvalidUTF8 rejects input containing byte sequences which are not
+// valid UTF-8 instead of reading them as the replacement character U+FFFD.
+func WithRejectInvalidUTF8() Option {
+	return func(o *options) {
+		o.rejectInvalidUTF8 = true
+	}
+}
+
+// WithNestedValues parses values prefixed with "nested:" as expressions with
+// the same options and stores the resulting maps, e.g. "cfg=nested:a.b=1"
+// stores the map {"a": {"b": 1}} under the key "cfg". The paths and limits of
+// nested expressions continue the ones of the enclosing expression.
+func WithNestedValues() Option {
+	return func(o *options) {
+		o.nestedValues = true
+	}
+}
+
+// CoercionWarning reports a number converted from a literal the number does
+// not reproduce when formatted.
+type CoercionWarning struct {
+	Path    string      // The path of the value
+	Literal string      // The literal text of the value
+	Value   interface{} // The converted value
+}
+
+// WithCoercionWarnings appends a warning to the slice provided whenever
+// MergeValue converts a literal to a number losing its exact text, e.g. "1.10"
+// to 1.1 or "007" to 7. The values are converted regardless.
+func WithCoercionWarnings(warnings *[]CoercionWarning) Option {
+	return func(o *options) {
+		o.coercionWarnings = warnings
+	}
+}
+
+// WithNumericBools coerces any number at the paths a schema declares boolean,
+// storing true unless the number is zero, e.g. "active=5" stores true.
+func WithNumericBools() Option {
+	return func(o *options) {
+		o.numericBools = true
+	}
+}
+
+// WithTristateBools coerces the values at the paths a schema declares
+// boolean to *bool, storing a nil pointer for "unknown", "maybe" and "null"
+// in any case, e.g. "active=maybe" stores (*bool)(nil) and "active=true" a
+// pointer to true.
+func WithTristateBools() Option {
+	return func(o *options) {
+		o.tristateBools = true
+	}
+}
+
+// WithComments strips comments following values and records them in the map
+// provided under the paths of the values. A comment starts with a '#' outside
+// double quotes which either starts the value or follows a space, and runs to
+// the end of the input, commas included, e.g. "key=val # note" stores "val"
+// and records "note" under "key".
+func WithComments(comments map[string]string) Option {
+	return func(o *options) {
+		o.comments = comments
+	}
+}
+
+// WithGlobalValueSplit splits every value containing the separator into an
+// array of values, converted individually by MergeValue, e.g. "path=a;b"
+// stores ["a", "b"] with ';' as the separator. Values without the separator
+// stay scalar, and the separator can be escaped as "\;" to keep it literal.
+// The separator cannot be ',', which separates assignments, and the parsing
+// fails if it is.
+func WithGlobalValueSplit(sep rune) Option {
+	return func(o *options) {
+		o.valueSeparator = sep
+	}
+}
+
+// WithPreserveExistingTypes makes the parsing fail when a value overrides an
+// existing one of a different kind, e.g. "x=abc" overriding an integer.
+// New keys and keys holding null are not restricted. If safeConversions is
+// true, strings are converted as MergeValue does and integers become floats
+// before comparing the kinds, so MergeString can override an integer with
+// "x=5".
+func WithPreserveExistingTypes(safeConversions bool) Option {
+	return func(o *options) {
+		o.preserveTypes = true
+		o.safeConversions = safeConversions
+	}
+}
+
+// WithUnitSplitting converts numbers followed by units consisting of letters
+// to Quantity keeping the number and the unit separate, e.g. "2Mi" becomes
+// Quantity{Value: 2, Unit: "Mi"}. Numbers without units stay numeric. When
+// combined with WithQuantityParsing, known suffixes are converted to base
+// units instead.
+func WithUnitSplitting() Option {
+	return func(o *options) {
+		o.unitSplitting = true
+	}
+}
+
+// WithReferences parses values prefixed with "@ref:" as references to the
+// values at the paths following the prefix, e.g. "target=@ref:db.host" stores
+// Reference{Path: "db.host"}. The references are replaced with the values by
+// calling ResolveReferences once all the assignments are merged.
+func WithReferences() Option {
+	return func(o *options) {
+		o.references = true
+	}
+}
+
+// WithStringNormalizer normalizes every string value with the function
+// provided once the value is converted, e.g. trimming or lowercasing it.
+// Values of the other types, like numbers and booleans, are not affected,
+// while strings inside arrays produced by splitting values are normalized.
+func WithStringNormalizer(normalizer func(string) string) Option {
+	return func(o *options) {
+		o.stringNormalizer = normalizer
+	}
+}
+
+// WithBlockScalarFolding reshapes multi-line string values the way YAML
+// block scalars do. With fold the lines are joined with spaces like in a
+// folded '>' scalar, runs of blank lines becoming single new lines, and
+// otherwise the new lines are kept like in a literal '|' scalar. With
+// stripIndent the indentation common to the lines is removed, so
+// "key=\"\n  a\n  b\"" stores "a b" when folding.
+func WithBlockScalarFolding(fold, stripIndent bool) Option {
+	return func(o *options) {
+		o.foldLines = fold
+		o.stripIndent = stripIndent
+	}
+}
+
+// WithMatrixValues parses values in square brackets as arrays of comma
+// separated elements, which can be arrays themselves, so "m=[[1,2],[3,4]]"
+// stores a two by two matrix of integers. Rows of different lengths are
+// allowed, and values starting with '[' which are not valid arrays fail the
+// parsing.
+func WithMatrixValues() Option {
+	return func(o *options) {
+		o.matrixValues = true
+	}
+}
+
+// WithIndexRanges accepts inclusive ranges of array indices, assigning the
+// value to every index of the range, e.g. "foo[0:2]=x" assigns "x" to the
+// indices 0, 1 and 2. The last index of a range cannot precede the first one.
+// Ranges are not recognized in square brackets holding duration keys.
+func WithIndexRanges() Option {
+	return func(o *options) {
+		o.indexRanges = true
+	}
+}
+
+// WithShellSplit splits the values at the paths provided into arrays of words
+// the way a shell does, honoring quotes and escapes, e.g.
+// "cmd=echo \"hello world\" foo" stores ["echo", "hello world", "foo"]. The
+// words are kept strings, and an unterminated quote fails the parsing. The
+// splitting applies to MergeValue only.
+func WithShellSplit(paths ...string) Option {
+	return func(o *options) {
+		o.shellSplitPaths = map[string]bool{}
+		for _, path := range paths {
+			o.shellSplitPaths[path] = true
+		}
+	}
+}
+
+// WithHomogeneousArrays makes the parsing fail when a value is assigned to an
+// array element and its type differs from the type of any other element, e.g.
+// "foo[1]=abc" assigned to the array [1]. Null values are ignored, so the gaps
+// filled with nil do not count as elements of another type.
+func WithHomogeneousArrays() Option {
+	return func(o *options) {
+		o.homogeneousArrays = true
+	}
+}
+
+// WithPlusAsSpace makes MergeQuery decode '+' to a space, the way HTML forms
+// encode spaces, instead of keeping it literally.
+func WithPlusAsSpace() Option {
+	return func(o *options) {
+		o.plusAsSpace = true
+	}
+}
+
+// WithBareKeyBooleans accepts paths without assignments, setting their leaves
+// to true, and paths preceded by '!', setting their leaves to false, e.g.
+// "debug" stands for "debug=true" and "!debug" for "debug=false". A key
+// starting with '!' can be escaped as "\!".
+func WithBareKeyBooleans() Option {
+	return func(o *options) {
+		o.bareKeyBooleans = true
+	}
+}
+
+// WithTimeLayouts parses the values at the paths provided as times following
+// the Go time layouts declared for the paths, e.g. with the layout
+// "01/02/2006" at "date", "date=12/31/2021" stores the time.Time of that date.
+// Values not matching the layouts fail the parsing. The layouts apply to
+// MergeValue only.
+func WithTimeLayouts(layouts map[string]string) Option {
+	return func(o *options) {
+		o.timeLayouts = layouts
+	}
+}
+
+// WithUnixTimeParsing parses the integer values at the paths provided as Unix
+// times counted in the unit provided, e.g. time.Second or time.Millisecond,
+// and stores them as UTC time.Time values, e.g. "created=1609459200" stores
+// the beginning of 2021 with seconds as the unit. Other values fall back to
+// the time layouts declared for the paths by WithTimeLayouts, and fail the
+// parsing at the paths having no layouts. The parsing applies to MergeValue
+// only.
+func WithUnixTimeParsing(unit time.Duration, paths ...string) Option {
+	return func(o *options) {
+		o.unixTimeUnit = unit
+		o.unixTimePaths = map[string]bool{}
+		for _, path := range paths {
+			o.unixTimePaths[path] = true
+		}
+	}
+}
+
+// WithTrimTrailingNils removes the trailing nil elements of an array whenever
+// null is assigned to its element, e.g. "foo[1]=null" turns ["a", "b"] into
+// ["a"] and "foo[0]=null" turns ["a"] into []. The gaps between the other
+// elements are kept.
+func WithTrimTrailingNils() Option {
+	return func(o *options) {
+		o.trimTrailingNils = true
+	}
+}
+
+// WithVersionConstraints parses the values at the paths provided as version
+// constraints separated by spaces, e.g. "version=>=1.2.0 <2.0.0", which can
+// be checked against versions later. A version without an operator is an
+// exact constraint, and invalid constraints fail the parsing. The parsing
+// applies to MergeValue only.
+func WithVersionConstraints(paths ...string) Option {
+	return func(o *options) {
+		o.versionPaths = map[string]bool{}
+		for _, path := range paths {
+			o.versionPaths[path] = true
+		}
+	}
+}
+
+// WithBareWordFallback accepts inputs containing no unescaped '=', '.' or '['
+// instead of failing the parsing. If the default key is empty, such an input
+// is a bare key as with WithBareKeyBooleans, so "debug" stands for
+// "debug=true" and "!debug" for "debug=false". Otherwise, the
+// input is assigned to the default key, so with the key "msg", "hello"
+// stands for "msg=hello".
+func WithBareWordFallback(defaultKey string) Option {
+	return func(o *options) {
+		o.bareWordFallback = true
+		o.fallbackKey = defaultKey
+	}
+}
+
+// WithIndexNames accepts names in square brackets standing for the array
+// indices in the table provided, e.g. "pipeline[extract]=x" assigns to the
+// index of "extract". Numeric indices are accepted as usual, and names not in
+// the table fail the parsing unless they are duration keys.
+func WithIndexNames(names map[string]int) Option {
+	return func(o *options) {
+		o.indexNames = names
+	}
+}
+
+// WithValueSigils forces the types of values starting with a sigil: '#' for
+// int64, '~' for float64, '?' for bool and '$' for string. The rest of the
+// value is parsed as the type, so "count=#08" stores int64(8) and "flag=?1"
+// stores true, and a value not matching the type fails the parsing. A
+// leading sigil escaped with a backslash is kept literal, e.g. "tag=\#08"
+// stores "#08".
+func WithValueSigils() Option {
+	return func(o *options) {
+		o.valueSigils = true
+	}
+}
+
+// WithRegexpValues compiles values prefixed with "re:" as regular
+// expressions and stores the resulting *regexp.Regexp, so "match=re:^foo.*$"
+// stores a compiled matcher. The escapes of such values are kept for the
+// expression, and an invalid expression fails the parsing. The prefix can be
+// escaped as "\re:" for keeping the value a string.
+func WithRegexpValues() Option {
+	return func(o *options) {
+		o.regexpValues = true
+	}
+}
+
+// WithOrderedValues parses braced values as fields separated by semicolons
+// instead of brace lists, every field being an expression with the same
+// options, and stores the resulting ordered maps keeping the order of the
+// fields, e.g. "info={name=a; desc=b}" stores an OrderedMap with the keys
+// "name" and "desc" under the key "info". Semicolons can be escaped with a backslash,
+// and the ones inside nested braced values separate the nested fields.
+func WithOrderedValues() Option {
+	return func(o *options) {
+		o.orderedValues = true
+	}
+}
+
+// WithAnchors parses values prefixed with '&' and an anchor name followed by
+// spaces as anchor definitions, recording the values following the names in
+// the map provided, and values consisting of '*' and an anchor name as
+// aliases, replaced with copies of the anchored values. With a map shared
+// across a batch of assignments, "base=&b {host=x}" followed by "prod=*b"
+// stores the same value under "base" and "prod". The anchored value is
+// converted as usual, so "{host=x}" is a map with WithOrderedValues only, and
+// a brace list ["host=x"] otherwise. An alias of an undefined anchor fails
+// the parsing.
+func WithAnchors(anchors map[string]interface{}) Option {
+	return func(o *options) {
+		o.anchors = anchors
+	}
+}
@@ -0,0 +1,3011 @@
+package djson
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_Parser_Renders_Templates(t *testing.T) {
+	data := map[string]interface{}{
+		"Name": "foo",
+		"Port": 8080,
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a template value", "key=tpl:{{ .Name }}",
+			map[string]interface{}{
+				"key": "foo",
+			},
+		),
+		newParserTestCase(
+			"a rendered value is converted", "key=tpl:{{ .Port }}",
+			map[string]interface{}{
+				"key": int64(8080),
+			},
+		),
+		newParserTestCase(
+			"an escaped template prefix", "key=\\tpl:{{ .Name }}",
+			map[string]interface{}{
+				"key": "tpl:{{ .Name }}",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithTemplateData(data))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an undefined field", "key=tpl:{{ .Missing }}",
+			"unable to parse \"key=tpl:{{ .Missing }}\", template: value:1:3: executing \"value\" at <.Missing>: map has no entry for key \"Missing\"",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithTemplateData(data))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Records_Assignment_Order(t *testing.T) {
+	input := "foo[2]=c,foo[0]=a,foo[1]=b,bar.baz[0][1]=d"
+	m := map[string]interface{}{}
+	order := map[string][]int{}
+	for _, part := range strings.Split(input, ",") {
+		if err := MergeValue(m, part, WithAssignmentOrder(order)); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+
+	expectedOrder := map[string][]int{
+		"foo":        {2, 0, 1},
+		"bar.baz":    {0},
+		"bar.baz[0]": {1},
+	}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Errorf("Expected assignment order %v, got %v", expectedOrder, order)
+	}
+
+	expected := map[string]interface{}{
+		"foo": []interface{}{"a", "b", "c"},
+		"bar": map[string]interface{}{
+			"baz": []interface{}{
+				[]interface{}{nil, "d"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected %v, got %v", expected, m)
+	}
+}
+
+func Test_Parser_Rejects_Spaces_In_Values(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a value with no spaces", "name=John",
+			map[string]interface{}{
+				"name": "John",
+			},
+		),
+		newParserTestCase(
+			"a quoted value with a space", "name=\"John Smith\"",
+			map[string]interface{}{
+				"name": "John Smith",
+			},
+		),
+		newParserTestCase(
+			"a key with a space", "first name=John",
+			map[string]interface{}{
+				"first name": "John",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithRejectSpacesInValues())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unquoted value with a space", "name=John Smith",
+			"unable to parse \"name=John Smith\", unquoted value \"John Smith\" contains spaces",
+		),
+		newParserErrorTestCase(
+			"an unquoted value with a tab", "name=John\tSmith",
+			"unable to parse \"name=John\tSmith\", unquoted value \"John\tSmith\" contains spaces",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeString(m, test.input, WithRejectSpacesInValues())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Parses_IP_Addresses(t *testing.T) {
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"an IPv4 address", "key=192.168.0.1",
+			map[string]interface{}{
+				"key": net.ParseIP("192.168.0.1"),
+			},
+		),
+		newParserTestCase(
+			"an IPv6 address", "key=2001:db8::1",
+			map[string]interface{}{
+				"key": net.ParseIP("2001:db8::1"),
+			},
+		),
+		newParserTestCase(
+			"a CIDR", "key=10.0.0.0/8",
+			map[string]interface{}{
+				"key": ipNet,
+			},
+		),
+		newParserTestCase(
+			"a number", "key=1.5",
+			map[string]interface{}{
+				"key": float64(1.5),
+			},
+		),
+		newParserTestCase(
+			"a non-address string", "key=localhost",
+			map[string]interface{}{
+				"key": "localhost",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithIPParsing())
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Resolves_Conflicts(t *testing.T) {
+	keepMax := func(path string, old, new interface{}) interface{} {
+		o, ok1 := old.(int64)
+		n, ok2 := new.(int64)
+		if ok1 && ok2 && o > n {
+			return old
+		}
+		return new
+	}
+	concat := func(path string, old, new interface{}) interface{} {
+		return fmt.Sprintf("%v%v", old, new)
+	}
+	var paths []string
+	recordPath := func(path string, old, new interface{}) interface{} {
+		paths = append(paths, path)
+		return new
+	}
+
+	testCases := []struct {
+		parserTestCase
+		resolver ConflictResolver
+	}{
+		{
+			newParserTestCase(
+				"keeping the larger integer", "x=5,x=3,y=1,y=2",
+				map[string]interface{}{
+					"x": int64(5),
+					"y": int64(2),
+				},
+			),
+			keepMax,
+		},
+		{
+			newParserTestCase(
+				"concatenating strings", "foo[0]=a,foo[0]=b,foo[0]=c",
+				map[string]interface{}{
+					"foo": []interface{}{"abc"},
+				},
+			),
+			concat,
+		},
+		{
+			newParserTestCase(
+				"overwriting a null value", "x=null,x=2",
+				map[string]interface{}{
+					"x": int64(2),
+				},
+			),
+			concat,
+		},
+		{
+			newParserTestCase(
+				"receiving the path", "a.b[1]=x,a.b[1]=y",
+				map[string]interface{}{
+					"a": map[string]interface{}{
+						"b": []interface{}{nil, "y"},
+					},
+				},
+			),
+			recordPath,
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			err := MergeValue(m, part, WithConflictResolver(test.resolver))
+			if err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test.parserTestCase, m)
+	}
+
+	expectedPaths := []string{"a.b[1]"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("Expected resolved paths %v, got %v", expectedPaths, paths)
+	}
+}
+
+func Test_Parser_Limits_Depth(t *testing.T) {
+	newExisting := func() map[string]interface{} {
+		return map[string]interface{}{
+			"a": map[string]interface{}{
+				"b": []interface{}{
+					map[string]interface{}{
+						"c": map[string]interface{}{
+							"d": map[string]interface{}{
+								"e": "deep",
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	test := newParserTestCase(
+		"a path within the limit", "a.b[0].c=x",
+		map[string]interface{}{
+			"a": map[string]interface{}{
+				"b": []interface{}{
+					map[string]interface{}{
+						"c": "x",
+					},
+				},
+			},
+		},
+	)
+	m := newExisting()
+	err := MergeValue(m, test.input, WithMaxDepth(4))
+	assertNoError(t, err, test, m)
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a path into an existing structure exceeding the limit", "a.b[0].c.d=x",
+			"unable to parse \"a.b[0].c.d=x\", path depth exceeds the maximum of 4",
+		),
+		newParserErrorTestCase(
+			"a path exceeding the limit", "a.b[0].c.d.e.f.g=x",
+			"unable to parse \"a.b[0].c.d.e.f.g=x\", path depth exceeds the maximum of 4",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := newExisting()
+		err := MergeValue(m, test.input, WithMaxDepth(4))
+		assertError(t, err, test)
+		if !reflect.DeepEqual(m, newExisting()) {
+			t.Errorf("In the case of %s expected the map to stay unchanged, got %v", test.desc, m)
+		}
+	}
+}
+
+func Test_Parser_Parses_UUIDs(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a UUID", "id=550e8400-e29b-41d4-a716-446655440000",
+			map[string]interface{}{
+				"id": UUID{
+					0x55, 0x0e, 0x84, 0x00, 0xe2, 0x9b, 0x41, 0xd4,
+					0xa7, 0x16, 0x44, 0x66, 0x55, 0x44, 0x00, 0x00,
+				},
+			},
+		),
+		newParserTestCase(
+			"an upper case UUID", "id=550E8400-E29B-41D4-A716-446655440000",
+			map[string]interface{}{
+				"id": UUID{
+					0x55, 0x0e, 0x84, 0x00, 0xe2, 0x9b, 0x41, 0xd4,
+					0xa7, 0x16, 0x44, 0x66, 0x55, 0x44, 0x00, 0x00,
+				},
+			},
+		),
+		newParserTestCase(
+			"a UUID with a missing digit", "id=550e8400-e29b-41d4-a716-44665544000",
+			map[string]interface{}{
+				"id": "550e8400-e29b-41d4-a716-44665544000",
+			},
+		),
+		newParserTestCase(
+			"a UUID with a non-hex digit", "id=550e8400-e29b-41d4-a716-44665544000g",
+			map[string]interface{}{
+				"id": "550e8400-e29b-41d4-a716-44665544000g",
+			},
+		),
+		newParserTestCase(
+			"a UUID with misplaced dashes", "id=550e84-00e29b-41d4-a716-446655440000",
+			map[string]interface{}{
+				"id": "550e84-00e29b-41d4-a716-446655440000",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithUUIDParsing())
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_UUID_String(t *testing.T) {
+	str := "550e8400-e29b-41d4-a716-446655440000"
+	u, ok := parseUUID(str)
+	if !ok {
+		t.Fatalf("Expected \"%s\" to be parsed", str)
+	}
+	if u.String() != str {
+		t.Errorf("Expected \"%s\", got \"%s\"", str, u.String())
+	}
+}
+
+func Test_Parser_Parses_Quantities(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"mebibytes", "size=2Mi",
+			map[string]interface{}{
+				"size": int64(2 * 1024 * 1024),
+			},
+		),
+		newParserTestCase(
+			"kilo", "rate=1K",
+			map[string]interface{}{
+				"rate": int64(1000),
+			},
+		),
+		newParserTestCase(
+			"milli", "cpu=500m",
+			map[string]interface{}{
+				"cpu": float64(0.5),
+			},
+		),
+		newParserTestCase(
+			"a fractional exact value", "size=1.5Ki",
+			map[string]interface{}{
+				"size": int64(1536),
+			},
+		),
+		newParserTestCase(
+			"a negative value", "offset=-3G",
+			map[string]interface{}{
+				"offset": int64(-3e9),
+			},
+		),
+		newParserTestCase(
+			"an unknown suffix", "size=2Xi",
+			map[string]interface{}{
+				"size": "2Xi",
+			},
+		),
+		newParserTestCase(
+			"a suffix without a number", "unit=Mi",
+			map[string]interface{}{
+				"unit": "Mi",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithQuantityParsing())
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Parses_Byte_Sizes(t *testing.T) {
+	testCases := []struct {
+		parserTestCase
+		opts []Option
+	}{
+		{
+			newParserTestCase(
+				"decimal gigabytes", "disk=10GB",
+				map[string]interface{}{
+					"disk": int64(10000000000),
+				},
+			),
+			[]Option{WithByteSizeParsing(false)},
+		},
+		{
+			newParserTestCase(
+				"binary gigabytes", "disk=10GiB",
+				map[string]interface{}{
+					"disk": int64(10737418240),
+				},
+			),
+			[]Option{WithByteSizeParsing(false)},
+		},
+		{
+			newParserTestCase(
+				"decimal kilobytes", "buffer=512KB",
+				map[string]interface{}{
+					"buffer": int64(512000),
+				},
+			),
+			[]Option{WithByteSizeParsing(false)},
+		},
+		{
+			newParserTestCase(
+				"decimal kilobytes taken for binary ones", "buffer=512KB",
+				map[string]interface{}{
+					"buffer": int64(524288),
+				},
+			),
+			[]Option{WithByteSizeParsing(true)},
+		},
+		{
+			newParserTestCase(
+				"a fractional size", "disk=1.5GB",
+				map[string]interface{}{
+					"disk": int64(1500000000),
+				},
+			),
+			[]Option{WithByteSizeParsing(false)},
+		},
+		{
+			newParserTestCase(
+				"a bare number", "disk=1024",
+				map[string]interface{}{
+					"disk": int64(1024),
+				},
+			),
+			[]Option{WithByteSizeParsing(false)},
+		},
+		{
+			newParserTestCase(
+				"an unknown suffix", "disk=10GX",
+				map[string]interface{}{
+					"disk": "10GX",
+				},
+			),
+			[]Option{WithByteSizeParsing(false)},
+		},
+		{
+			newParserTestCase(
+				"a size which is not a whole number of bytes", "disk=0.5B",
+				map[string]interface{}{
+					"disk": "0.5B",
+				},
+			),
+			[]Option{WithByteSizeParsing(false)},
+		},
+		{
+			newParserTestCase(
+				"sizes at designated paths", "disk=10GB,label=10GB",
+				map[string]interface{}{
+					"disk":  int64(10000000000),
+					"label": "10GB",
+				},
+			),
+			[]Option{WithByteSizeParsing(false, "disk")},
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, test.opts...)
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+}
+
+func Test_Parser_Checks_Numeric_Ranges(t *testing.T) {
+	ranges := map[string][2]float64{
+		"port":          {1, 65535},
+		"limits[0].cpu": {0, 1.5},
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"an in-range integer", "port=8080",
+			map[string]interface{}{
+				"port": int64(8080),
+			},
+		),
+		newParserTestCase(
+			"an in-range float", "limits[0].cpu=0.5",
+			map[string]interface{}{
+				"limits": []interface{}{
+					map[string]interface{}{
+						"cpu": float64(0.5),
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"a value at an unrestricted path", "host=70000",
+			map[string]interface{}{
+				"host": int64(70000),
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithNumericRanges(ranges))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an out-of-range integer", "port=70000",
+			"unable to parse \"port=70000\", value 70000 at path \"port\" is out of range [1, 65535]",
+		),
+		newParserErrorTestCase(
+			"an out-of-range float", "limits[0].cpu=1.75",
+			"unable to parse \"limits[0].cpu=1.75\", value 1.75 at path \"limits[0].cpu\" is out of range [0, 1.5]",
+		),
+		newParserErrorTestCase(
+			"a non-numeric value", "port=http",
+			"unable to parse \"port=http\", value \"http\" at path \"port\" is not a number",
+		),
+		newParserErrorTestCase(
+			"an empty value", "port=",
+			"unable to parse \"port=\", value \"\" at path \"port\" is not a number",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithNumericRanges(ranges))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Collects_Repeated_Keys(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"two repeats", "tag=a,tag=b",
+			map[string]interface{}{
+				"tag": []interface{}{"a", "b"},
+			},
+		),
+		newParserTestCase(
+			"three repeats", "foo.tag=a,foo.tag=b,foo.tag=c",
+			map[string]interface{}{
+				"foo": map[string]interface{}{
+					"tag": []interface{}{"a", "b", "c"},
+				},
+			},
+		),
+		newParserTestCase(
+			"a repeat after a null value", "tag=null,tag=a",
+			map[string]interface{}{
+				"tag": "a",
+			},
+		),
+		newParserTestCase(
+			"an indexed assignment overwrites", "tag=a,tag=b,tag[0]=x",
+			map[string]interface{}{
+				"tag": []interface{}{"x", "b"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			err := MergeValue(m, part, WithRepeatedKeysAsList())
+			if err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test, m)
+	}
+
+	m := map[string]interface{}{}
+	MergeValue(m, "tag=a", WithRepeatedKeysAsList())
+	MergeValue(m, "tag=b")
+	expected := map[string]interface{}{
+		"tag": "b",
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected the last assignment to win without the option, got %v", m)
+	}
+
+	m = map[string]interface{}{}
+	MergeValue(m, "tag.foo=a", WithRepeatedKeysAsList())
+	test := newParserErrorTestCase(
+		"a repeat on a map", "tag=b",
+		"unable to parse \"tag=b\", unable to append a repeated value to a map",
+	)
+	assertError(t, MergeValue(m, test.input, WithRepeatedKeysAsList()), test)
+}
+
+func Test_Parser_Parses_Complex_Numbers(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a complex number", "z=1+2i",
+			map[string]interface{}{
+				"z": complex(1, 2),
+			},
+		),
+		newParserTestCase(
+			"an imaginary number", "z=3i",
+			map[string]interface{}{
+				"z": complex(0, 3),
+			},
+		),
+		newParserTestCase(
+			"a real number", "z=1.5",
+			map[string]interface{}{
+				"z": float64(1.5),
+			},
+		),
+		newParserTestCase(
+			"a non-complex string", "z=1+2j",
+			map[string]interface{}{
+				"z": "1+2j",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithComplexParsing())
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Limits_New_Keys(t *testing.T) {
+	newExisting := func() map[string]interface{} {
+		return map[string]interface{}{
+			"a": map[string]interface{}{
+				"b": "x",
+			},
+		}
+	}
+
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"creating keys up to the limit", "a.c.d=y",
+			map[string]interface{}{
+				"a": map[string]interface{}{
+					"b": "x",
+					"c": map[string]interface{}{
+						"d": "y",
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"overwriting existing keys", "a.b=y",
+			map[string]interface{}{
+				"a": map[string]interface{}{
+					"b": "y",
+				},
+			},
+		),
+		newParserTestCase(
+			"creating keys in an array", "e[0].f=y",
+			map[string]interface{}{
+				"a": map[string]interface{}{
+					"b": "x",
+				},
+				"e": []interface{}{
+					map[string]interface{}{
+						"f": "y",
+					},
+				},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := newExisting()
+		err := MergeValue(m, test.input, WithMaxKeys(2))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"creating a key over the limit", "a.c.d.e=y",
+			"unable to parse \"a.c.d.e=y\", number of new map keys exceeds the maximum of 2",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := newExisting()
+		err := MergeValue(m, test.input, WithMaxKeys(2))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Parses_Patterns(t *testing.T) {
+	type semver struct {
+		Major, Minor, Patch int
+	}
+	atoi := func(s string) int {
+		i, _ := strconv.Atoi(s)
+		return i
+	}
+	parsers := []PatternParser{
+		{
+			Pattern: regexp.MustCompile(`^v(\d+)\.(\d+)\.(\d+)$`),
+			Convert: func(match []string) interface{} {
+				return semver{atoi(match[1]), atoi(match[2]), atoi(match[3])}
+			},
+		},
+		{
+			Pattern: regexp.MustCompile(`^v`),
+			Convert: func(match []string) interface{} {
+				return "shadowed for semvers"
+			},
+		},
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a semver", "version=v1.2.3",
+			map[string]interface{}{
+				"version": semver{1, 2, 3},
+			},
+		),
+		newParserTestCase(
+			"a value matching the second pattern", "version=v1",
+			map[string]interface{}{
+				"version": "shadowed for semvers",
+			},
+		),
+		newParserTestCase(
+			"a non-matching value", "version=1.2.3",
+			map[string]interface{}{
+				"version": "1.2.3",
+			},
+		),
+		newParserTestCase(
+			"a number", "version=1.2",
+			map[string]interface{}{
+				"version": float64(1.2),
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithPatternParsers(parsers...))
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Uses_Literals_Table(t *testing.T) {
+	french := LiteralsTable{
+		True:  []string{"vrai"},
+		False: []string{"faux"},
+		Null:  []string{"nul"},
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a French true", "x=vrai",
+			map[string]interface{}{
+				"x": true,
+			},
+		),
+		newParserTestCase(
+			"a French false", "x=faux",
+			map[string]interface{}{
+				"x": false,
+			},
+		),
+		newParserTestCase(
+			"a French null", "x=nul",
+			map[string]interface{}{
+				"x": nil,
+			},
+		),
+		newParserTestCase(
+			"an English true", "x=true",
+			map[string]interface{}{
+				"x": "true",
+			},
+		),
+		newParserTestCase(
+			"an English null", "x=null",
+			map[string]interface{}{
+				"x": "null",
+			},
+		),
+		newParserTestCase(
+			"a case sensitive mismatch", "x=Vrai",
+			map[string]interface{}{
+				"x": "Vrai",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithLiterals(french))
+		assertNoError(t, err, test, m)
+	}
+
+	bilingual := DefaultLiterals()
+	bilingual.True = append(bilingual.True, "vrai")
+	bilingual.IgnoreCase = true
+	testCases = []parserTestCase{
+		newParserTestCase(
+			"a case insensitive French true", "x=VRAI",
+			map[string]interface{}{
+				"x": true,
+			},
+		),
+		newParserTestCase(
+			"an included English true", "x=true",
+			map[string]interface{}{
+				"x": true,
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithLiterals(bilingual))
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Unquotes_Go_Strings(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a double quoted string with a tab escape", "key=\"a\\tb\"",
+			map[string]interface{}{
+				"key": "a\tb",
+			},
+		),
+		newParserTestCase(
+			"a double quoted number", "key=\"1000\"",
+			map[string]interface{}{
+				"key": "1000",
+			},
+		),
+		newParserTestCase(
+			"a raw string", "key=`a\\tb`",
+			map[string]interface{}{
+				"key": "a\\tb",
+			},
+		),
+		newParserTestCase(
+			"an unquoted value", "key=a\\tb",
+			map[string]interface{}{
+				"key": "a\\tb",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithGoUnquote())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unknown escape", "key=\"a\\qb\"",
+			"unable to parse \"key=\"a\\qb\"\", unable to unquote \"a\\qb\", invalid syntax",
+		),
+		newParserErrorTestCase(
+			"an unescaped inner quote", "key=\"a\"b\"",
+			"unable to parse \"key=\"a\"b\"\", unable to unquote \"a\"b\", invalid syntax",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeString(m, test.input, WithGoUnquote())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Parses_Duration_Keys(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"duration keys", "series[1h]=x,series[30m]=y,series[60m]=z",
+			map[string]interface{}{
+				"series": map[string]interface{}{
+					"1h0m0s": "z",
+					"30m0s":  "y",
+				},
+			},
+		),
+		newParserTestCase(
+			"a nested duration key", "series[1h].value=x,series[1h][5s]=y",
+			map[string]interface{}{
+				"series": map[string]interface{}{
+					"1h0m0s": map[string]interface{}{
+						"value": "x",
+						"5s":    "y",
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"numeric indices", "series[1]=x,series[0]=y",
+			map[string]interface{}{
+				"series": []interface{}{"y", "x"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			err := MergeValue(m, part, WithDurationKeys())
+			if err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test, m)
+	}
+
+	test := newParserErrorTestCase(
+		"an invalid duration", "series[1y]=x",
+		"unable to parse \"series[1y]=x\", invalid duration key \"1y\"",
+	)
+	assertError(t, MergeValue(map[string]interface{}{}, test.input, WithDurationKeys()), test)
+}
+
+func Test_Parser_Dispatches_Union_Prefixes(t *testing.T) {
+	type workerNode struct {
+		Name string
+	}
+	type masterNode struct {
+		Name string
+	}
+	type labeledMasterNode struct {
+		Name string
+	}
+	parsers := map[string]UnionParser{
+		"worker:": func(val string) (interface{}, error) {
+			if val == "" {
+				return nil, errors.New("worker name is required")
+			}
+			return workerNode{val}, nil
+		},
+		"master:": func(val string) (interface{}, error) {
+			return masterNode{val}, nil
+		},
+		"master:labeled:": func(val string) (interface{}, error) {
+			return labeledMasterNode{val}, nil
+		},
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a worker prefix", "node=worker:node1",
+			map[string]interface{}{
+				"node": workerNode{"node1"},
+			},
+		),
+		newParserTestCase(
+			"a master prefix", "node=master:node2",
+			map[string]interface{}{
+				"node": masterNode{"node2"},
+			},
+		),
+		newParserTestCase(
+			"the longest prefix", "node=master:labeled:node3",
+			map[string]interface{}{
+				"node": labeledMasterNode{"node3"},
+			},
+		),
+		newParserTestCase(
+			"an unprefixed value", "node=10",
+			map[string]interface{}{
+				"node": int64(10),
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithUnionParsers(parsers))
+		assertNoError(t, err, test, m)
+	}
+
+	test := newParserErrorTestCase(
+		"a parser error", "node=worker:",
+		"unable to parse \"node=worker:\", worker name is required",
+	)
+	assertError(t, MergeValue(map[string]interface{}{}, test.input, WithUnionParsers(parsers)), test)
+}
+
+func Test_Parser_Checks_Balance(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"balanced brackets and quotes", "foo[0][1]=\"[a\"",
+			map[string]interface{}{
+				"foo": []interface{}{
+					[]interface{}{nil, "[a"},
+				},
+			},
+		),
+		newParserTestCase(
+			"escaped brackets and quotes", "foo\\[=\\\"",
+			map[string]interface{}{
+				"foo[": "\"",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithBalanceCheck())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unbalanced open square bracket", "foo[0.bar[1]=x",
+			"unable to parse \"foo[0.bar[1]=x\", unbalanced '[' in position 4",
+		),
+		newParserErrorTestCase(
+			"an unbalanced close square bracket", "foo]=x",
+			"unable to parse \"foo]=x\", unbalanced ']' in position 4",
+		),
+		newParserErrorTestCase(
+			"an unbalanced quote", "foo=\"bar",
+			"unable to parse \"foo=\"bar\", unbalanced '\"' in position 5",
+		),
+		newParserErrorTestCase(
+			"a balanced input failing to parse", "foo[a]=x",
+			"unable to parse \"foo[a]=x\", in position 5 got unexpected character: U+0061 'a', expecting an array index",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithBalanceCheck())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Recognizes_Sentinels(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"an empty sentinel", "key=_empty_",
+			map[string]interface{}{
+				"key": "",
+			},
+		),
+		newParserTestCase(
+			"a null sentinel", "key=_null_",
+			map[string]interface{}{
+				"key": nil,
+			},
+		),
+		newParserTestCase(
+			"a value containing a sentinel", "key=_empty__",
+			map[string]interface{}{
+				"key": "_empty__",
+			},
+		),
+		newParserTestCase(
+			"a null literal", "key=null",
+			map[string]interface{}{
+				"key": nil,
+			},
+		),
+		newParserTestCase(
+			"a number", "key=10",
+			map[string]interface{}{
+				"key": int64(10),
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithEmptySentinel("_empty_"), WithNullSentinel("_null_"))
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Collects_Multi_Values(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"repeated assignments", "header.Set=a,header.Add=b,header.Set=c",
+			map[string]interface{}{
+				"header": []KeyValue{
+					{"Set", "a"},
+					{"Add", "b"},
+					{"Set", "c"},
+				},
+			},
+		),
+		newParserTestCase(
+			"a nested multi-value path", "req[0].header.Set=3,req[0].header.Set=2",
+			map[string]interface{}{
+				"req": []interface{}{
+					map[string]interface{}{
+						"header": []KeyValue{
+							{"Set", int64(3)},
+							{"Set", int64(2)},
+						},
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"a regular path", "other.Set=a,other.Set=b",
+			map[string]interface{}{
+				"other": map[string]interface{}{
+					"Set": "b",
+				},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			err := MergeValue(m, part, WithMultiValuePaths("header", "req[0].header"))
+			if err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test, m)
+	}
+
+	test := newParserErrorTestCase(
+		"a nested assignment", "header.Set.X=a",
+		"unable to parse \"header.Set.X=a\", multi-value path \"header\" accepts direct assignments only",
+	)
+	assertError(t, MergeValue(map[string]interface{}{}, test.input, WithMultiValuePaths("header")), test)
+}
+
+func Test_Parser_Expands_Globs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.yaml":      {},
+		"b.yaml":      {},
+		"c.json":      {},
+		"conf/d.yaml": {},
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a pattern matching two files", "files=*.yaml",
+			map[string]interface{}{
+				"files": []interface{}{"a.yaml", "b.yaml"},
+			},
+		),
+		newParserTestCase(
+			"a pattern matching no files", "files=*.toml",
+			map[string]interface{}{
+				"files": []interface{}{},
+			},
+		),
+		newParserTestCase(
+			"a pattern in a directory", "deploy.files=conf/*.yaml",
+			map[string]interface{}{
+				"deploy": map[string]interface{}{
+					"files": []interface{}{"conf/d.yaml"},
+				},
+			},
+		),
+		newParserTestCase(
+			"a value at another path", "other=*.yaml",
+			map[string]interface{}{
+				"other": "*.yaml",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithGlobPaths(fsys, "files", "deploy.files"))
+		assertNoError(t, err, test, m)
+	}
+
+	test := newParserErrorTestCase(
+		"an invalid pattern", "files=[",
+		"unable to parse \"files=[\", unable to expand \"[\", syntax error in pattern",
+	)
+	assertError(t, MergeValue(map[string]interface{}{}, test.input, WithGlobPaths(fsys, "files")), test)
+}
+
+func Test_Parser_Strips_Quotes(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a double quoted value", "name=\"bob\"",
+			map[string]interface{}{
+				"name": "bob",
+			},
+		),
+		newParserTestCase(
+			"a single quoted value", "name='bob smith'",
+			map[string]interface{}{
+				"name": "bob smith",
+			},
+		),
+		newParserTestCase(
+			"a quoted value with escapes", "name=\"a\\tb\"",
+			map[string]interface{}{
+				"name": "a\\tb",
+			},
+		),
+		newParserTestCase(
+			"an unmatched quote", "name=\"bob'",
+			map[string]interface{}{
+				"name": "\"bob'",
+			},
+		),
+		newParserTestCase(
+			"a single quote", "name=\"",
+			map[string]interface{}{
+				"name": "\"",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeString(m, test.input, WithStripQuotes())
+		assertNoError(t, err, test, m)
+	}
+
+	test := newParserTestCase(
+		"a quoted number", "count=\"10\"",
+		map[string]interface{}{
+			"count": "10",
+		},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input, WithStripQuotes()), test, m)
+}
+
+func Test_Parser_Limits_Gap_Fill(t *testing.T) {
+	newExisting := func() map[string]interface{} {
+		return map[string]interface{}{
+			"a": []interface{}{"x", "y"},
+		}
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"nested gaps up to the limit", "b[2][1]=z",
+			map[string]interface{}{
+				"a": []interface{}{"x", "y"},
+				"b": []interface{}{
+					nil,
+					nil,
+					[]interface{}{nil, "z"},
+				},
+			},
+		),
+		newParserTestCase(
+			"gaps after existing elements", "a[5]=z",
+			map[string]interface{}{
+				"a": []interface{}{"x", "y", nil, nil, nil, "z"},
+			},
+		),
+		newParserTestCase(
+			"no gaps when appending", "a[2]=z",
+			map[string]interface{}{
+				"a": []interface{}{"x", "y", "z"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := newExisting()
+		err := MergeValue(m, test.input, WithMaxGapFill(3))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"nested gaps exceeding the limit", "b[2][2]=z",
+			"unable to parse \"b[2][2]=z\", number of filled array gaps exceeds the maximum of 3",
+		),
+		newParserErrorTestCase(
+			"gaps after existing elements exceeding the limit", "a[6]=z",
+			"unable to parse \"a[6]=z\", number of filled array gaps exceeds the maximum of 3",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := newExisting()
+		err := MergeValue(m, test.input, WithMaxGapFill(3))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Limits_Array_Indices(t *testing.T) {
+	errorTestCases := []struct {
+		parserErrorTestCase
+		opts []Option
+	}{
+		{
+			newParserErrorTestCase(
+				"an index exceeding the default limit", "foo[1000000000]=x",
+				"unable to parse \"foo[1000000000]=x\", array index 1000000000 exceeds the maximum of 10000",
+			),
+			nil,
+		},
+		{
+			newParserErrorTestCase(
+				"an index exceeding a custom limit", "foo[0].bar[11]=x",
+				"unable to parse \"foo[0].bar[11]=x\", array index 11 exceeds the maximum of 10",
+			),
+			[]Option{WithMaxIndex(10)},
+		},
+		{
+			newParserErrorTestCase(
+				"a range exceeding the limit", "foo[0:11]=x",
+				"unable to parse \"foo[0:11]=x\", array index 11 exceeds the maximum of 10",
+			),
+			[]Option{WithMaxIndex(10), WithIndexRanges()},
+		},
+		{
+			newParserErrorTestCase(
+				"a range of a sparse array exceeding the limit", "foo[9:11]=x",
+				"unable to parse \"foo[9:11]=x\", array index 11 exceeds the maximum of 10",
+			),
+			[]Option{WithMaxIndex(10), WithIndexRanges(), WithSparseArrays()},
+		},
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, test.opts...)
+		assertError(t, err, test.parserErrorTestCase)
+		if len(m) != 0 {
+			t.Errorf("In the case of %s expected the map to stay empty, got %v", test.desc, m)
+		}
+	}
+
+	testCases := []struct {
+		parserTestCase
+		opts []Option
+	}{
+		{
+			newParserTestCase(
+				"an index at the limit", "foo[10]=x",
+				map[string]interface{}{
+					"foo": []interface{}{nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, "x"},
+				},
+			),
+			[]Option{WithMaxIndex(10)},
+		},
+		{
+			newParserTestCase(
+				"a single index of a sparse array", "foo[1000000000]=x",
+				map[string]interface{}{
+					"foo": SparseArray{1000000000: "x"},
+				},
+			),
+			[]Option{WithSparseArrays()},
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, test.opts...)
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+
+	m := map[string]interface{}{}
+	if err := MergeValue(m, "foo[10001]=x", WithMaxIndex(0)); err != nil {
+		t.Errorf("Expected success without a limit, got %v", err)
+	}
+}
+
+func Test_Parser_Uses_Converter_Chain(t *testing.T) {
+	// Accepts UUIDs written as 32 hex digits without dashes
+	compactUUID := func(val string) (interface{}, bool) {
+		var u UUID
+		if len(val) != 32 {
+			return nil, false
+		}
+		if _, err := hex.Decode(u[:], []byte(val)); err != nil {
+			return nil, false
+		}
+		return u, true
+	}
+	id := UUID{
+		0x12, 0x34, 0x56, 0x78, 0x12, 0x34, 0x56, 0x78,
+		0x12, 0x34, 0x56, 0x78, 0x12, 0x34, 0x56, 0x78,
+	}
+	input := "id=12345678123456781234567812345678,a=10,b=true,c=null"
+	testCases := []struct {
+		parserTestCase
+		converters []Converter
+	}{
+		{
+			newParserTestCase(
+				"the default chain", input,
+				map[string]interface{}{
+					"id": float64(12345678123456781234567812345678),
+					"a":  int64(10),
+					"b":  true,
+					"c":  nil,
+				},
+			),
+			DefaultConverters(),
+		},
+		{
+			newParserTestCase(
+				"a UUID converter before the default chain", input,
+				map[string]interface{}{
+					"id": id,
+					"a":  int64(10),
+					"b":  true,
+					"c":  nil,
+				},
+			),
+			append([]Converter{compactUUID}, DefaultConverters()...),
+		},
+		{
+			newParserTestCase(
+				"a UUID converter after the default chain", input,
+				map[string]interface{}{
+					"id": float64(12345678123456781234567812345678),
+					"a":  int64(10),
+					"b":  true,
+					"c":  nil,
+				},
+			),
+			append(DefaultConverters(), compactUUID),
+		},
+		{
+			newParserTestCase(
+				"a UUID converter only", input,
+				map[string]interface{}{
+					"id": id,
+					"a":  "10",
+					"b":  "true",
+					"c":  "null",
+				},
+			),
+			[]Converter{compactUUID},
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			err := MergeValue(m, part, WithConverterChain(test.converters...))
+			if err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test.parserTestCase, m)
+	}
+}
+
+func Test_Parser_Default_Converters_Match_Parse(t *testing.T) {
+	for _, val := range []string{"1", "0", "t", "f"} {
+		input := "x=" + val
+		want, err := Parse(input)
+		if err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", input, err)
+		}
+		got, err := Parse(input, WithConverterChain(DefaultConverters()...))
+		if err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", input, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %v for \"%s\", got %v", want, input, got)
+		}
+	}
+}
+
+func Test_Parser_Detects_Booleans(t *testing.T) {
+	spellings := map[string]bool{
+		"ja": true, "nein": false,
+		"oui": true, "non": false,
+		"5": true,
+	}
+	detector := func(val string) (bool, bool) {
+		b, ok := spellings[strings.ToLower(val)]
+		return b, ok
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"German spellings", "a=ja,b=Nein",
+			map[string]interface{}{
+				"a": true,
+				"b": false,
+			},
+		),
+		newParserTestCase(
+			"French spellings", "a=oui,b=non",
+			map[string]interface{}{
+				"a": true,
+				"b": false,
+			},
+		),
+		newParserTestCase(
+			"a number detected before integers", "a=5",
+			map[string]interface{}{
+				"a": true,
+			},
+		),
+		newParserTestCase(
+			"numbers the detector declines", "a=2,b=0.5",
+			map[string]interface{}{
+				"a": int64(2),
+				"b": 0.5,
+			},
+		),
+		newParserTestCase(
+			"default literals the detector declines", "a=true,b=null,c=si",
+			map[string]interface{}{
+				"a": true,
+				"b": nil,
+				"c": "si",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			err := MergeValue(m, part, WithBoolDetector(detector))
+			if err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test, m)
+	}
+}
+
+func Test_Parser_Parses_Nested_Values(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a nested assignment", "cfg=nested:a=5",
+			map[string]interface{}{
+				"cfg": map[string]interface{}{
+					"a": int64(5),
+				},
+			},
+		),
+		newParserTestCase(
+			"a nested multi-level path", "cfg.x=nested:a.b[1]=true",
+			map[string]interface{}{
+				"cfg": map[string]interface{}{
+					"x": map[string]interface{}{
+						"a": map[string]interface{}{
+							"b": []interface{}{nil, true},
+						},
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"a nested expression in a nested one", "a=nested:b=nested:c=d",
+			map[string]interface{}{
+				"a": map[string]interface{}{
+					"b": map[string]interface{}{
+						"c": "d",
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"a value not starting with the prefix", "a=x nested:b=c",
+			map[string]interface{}{
+				"a": "x nested:b=c",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithNestedValues())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an invalid nested expression", "cfg=nested:a.=1",
+			"unable to parse \"cfg=nested:a.=1\", unable to parse \"a.=1\", in position 3 got unexpected character: U+003D '=', expecting a map key",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithNestedValues())
+		assertError(t, err, test)
+	}
+
+	test := newParserTestCase(
+		"the path of a nested expression", "cfg=nested:a.enabled=yes",
+		map[string]interface{}{
+			"cfg": map[string]interface{}{
+				"a": map[string]interface{}{
+					"enabled": true,
+				},
+			},
+		},
+	)
+	m := map[string]interface{}{}
+	schema := Schema{"cfg.a.enabled": TypeBool}
+	err := MergeValue(m, test.input, WithNestedValues(), WithSchema(schema))
+	assertNoError(t, err, test, m)
+}
+
+func Test_Parser_Reports_Coercion_Warnings(t *testing.T) {
+	input := "version=1.10,id=007,count=10,ratio=0.5,name=bob"
+	m := map[string]interface{}{}
+	var warnings []CoercionWarning
+	for _, part := range strings.Split(input, ",") {
+		if err := MergeValue(m, part, WithCoercionWarnings(&warnings)); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+
+	expectedWarnings := []CoercionWarning{
+		{Path: "version", Literal: "1.10", Value: 1.1},
+		{Path: "id", Literal: "007", Value: int64(7)},
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("\nIn the case of \"%s\"\nexpected warnings:\n\t%+v\ngot:\n\t%+v",
+			input, expectedWarnings, warnings)
+	}
+	test := newParserTestCase("coercing values with warnings", input,
+		map[string]interface{}{
+			"version": 1.1,
+			"id":      int64(7),
+			"count":   int64(10),
+			"ratio":   0.5,
+			"name":    "bob",
+		},
+	)
+	assertNoError(t, nil, test, m)
+}
+
+func Test_Parser_Records_Comments(t *testing.T) {
+	input := "key=val # note,a.b[1]=10\t#  the count ,c=\"x # y\" # quoted,d=x#y,e=# empty"
+	m := map[string]interface{}{}
+	comments := map[string]string{}
+	for _, part := range strings.Split(input, ",") {
+		if err := MergeValue(m, part, WithComments(comments)); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+
+	expectedComments := map[string]string{
+		"key":    "note",
+		"a.b[1]": "the count",
+		"c":      "quoted",
+		"e":      "empty",
+	}
+	if !reflect.DeepEqual(comments, expectedComments) {
+		t.Errorf("\nIn the case of \"%s\"\nexpected comments:\n\t%+v\ngot:\n\t%+v",
+			input, expectedComments, comments)
+	}
+	test := newParserTestCase("stripping comments", input,
+		map[string]interface{}{
+			"key": "val",
+			"a": map[string]interface{}{
+				"b": []interface{}{nil, int64(10)},
+			},
+			"c": "\"x # y\"",
+			"d": "x#y",
+			"e": "",
+		},
+	)
+	assertNoError(t, nil, test, m)
+}
+
+func Test_Parser_Keeps_Commas_In_Comments(t *testing.T) {
+	input := "a=5,key=val # a, b=2"
+	m := map[string]interface{}{}
+	comments := map[string]string{}
+	err := MergeValue(m, input, WithComments(comments))
+
+	expectedComments := map[string]string{
+		"key": "a, b=2",
+	}
+	if !reflect.DeepEqual(comments, expectedComments) {
+		t.Errorf("\nIn the case of \"%s\"\nexpected comments:\n\t%+v\ngot:\n\t%+v",
+			input, expectedComments, comments)
+	}
+	test := newParserTestCase("a comment with commas", input,
+		map[string]interface{}{
+			"a":   int64(5),
+			"key": "val",
+		},
+	)
+	assertNoError(t, err, test, m)
+}
+
+func Test_Parser_Splits_Values_Globally(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a split value", "path=/bin;/usr/bin",
+			map[string]interface{}{
+				"path": []interface{}{"/bin", "/usr/bin"},
+			},
+		),
+		newParserTestCase(
+			"split values converted individually", "a[0]=5;true;;x",
+			map[string]interface{}{
+				"a": []interface{}{
+					[]interface{}{int64(5), true, "", "x"},
+				},
+			},
+		),
+		newParserTestCase(
+			"an escaped separator", "a=x\\;y;z",
+			map[string]interface{}{
+				"a": []interface{}{"x;y", "z"},
+			},
+		),
+		newParserTestCase(
+			"escaped separators only", "a=x\\;y",
+			map[string]interface{}{
+				"a": "x;y",
+			},
+		),
+		newParserTestCase(
+			"a value without the separator", "a=10",
+			map[string]interface{}{
+				"a": int64(10),
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithGlobalValueSplit(';'))
+		assertNoError(t, err, test, m)
+	}
+
+	test := newParserTestCase(
+		"split strings", "a=1;\\;2",
+		map[string]interface{}{
+			"a": []interface{}{"1", ";2"},
+		},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeString(m, test.input, WithGlobalValueSplit(';')), test, m)
+
+	errorTest := newParserErrorTestCase(
+		"a comma as the separator", "p=a,b",
+		"unable to parse \"p=a,b\", invalid value separator ','",
+	)
+	assertError(t, MergeValue(map[string]interface{}{}, errorTest.input, WithGlobalValueSplit(',')), errorTest)
+}
+
+func Test_Parser_Preserves_Existing_Types(t *testing.T) {
+	newExisting := func() map[string]interface{} {
+		return map[string]interface{}{
+			"x":     int64(1),
+			"ratio": 0.5,
+			"name":  "bob",
+			"none":  nil,
+		}
+	}
+	testCases := []struct {
+		parserTestCase
+		merge           func(map[string]interface{}, string, ...Option) error
+		safeConversions bool
+	}{
+		{
+			newParserTestCase(
+				"overriding an integer with an integer", "x=5",
+				map[string]interface{}{
+					"x": int64(5), "ratio": 0.5, "name": "bob", "none": nil,
+				},
+			),
+			MergeValue, false,
+		},
+		{
+			newParserTestCase(
+				"adding a new key", "y=abc",
+				map[string]interface{}{
+					"x": int64(1), "ratio": 0.5, "name": "bob", "none": nil, "y": "abc",
+				},
+			),
+			MergeValue, false,
+		},
+		{
+			newParserTestCase(
+				"overriding a null value", "none=abc",
+				map[string]interface{}{
+					"x": int64(1), "ratio": 0.5, "name": "bob", "none": "abc",
+				},
+			),
+			MergeValue, false,
+		},
+		{
+			newParserTestCase(
+				"overriding an integer with a converted string", "x=5",
+				map[string]interface{}{
+					"x": int64(5), "ratio": 0.5, "name": "bob", "none": nil,
+				},
+			),
+			MergeString, true,
+		},
+		{
+			newParserTestCase(
+				"overriding a float with a converted integer", "ratio=2",
+				map[string]interface{}{
+					"x": int64(1), "ratio": float64(2), "name": "bob", "none": nil,
+				},
+			),
+			MergeValue, true,
+		},
+		{
+			newParserTestCase(
+				"overriding a string with a string", "name=10",
+				map[string]interface{}{
+					"x": int64(1), "ratio": 0.5, "name": "10", "none": nil,
+				},
+			),
+			MergeString, true,
+		},
+	}
+	for _, test := range testCases {
+		m := newExisting()
+		err := test.merge(m, test.input, WithPreserveExistingTypes(test.safeConversions))
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+
+	errorTestCases := []struct {
+		parserErrorTestCase
+		merge           func(map[string]interface{}, string, ...Option) error
+		safeConversions bool
+	}{
+		{
+			newParserErrorTestCase(
+				"overriding an integer with a string", "x=abc",
+				"unable to parse \"x=abc\", value of type string at path \"x\" does not match the existing type int64",
+			),
+			MergeValue, true,
+		},
+		{
+			newParserErrorTestCase(
+				"overriding an integer with a string without conversions", "x=5",
+				"unable to parse \"x=5\", value of type string at path \"x\" does not match the existing type int64",
+			),
+			MergeString, false,
+		},
+		{
+			newParserErrorTestCase(
+				"overriding a float with an integer without conversions", "ratio=2",
+				"unable to parse \"ratio=2\", value of type int64 at path \"ratio\" does not match the existing type float64",
+			),
+			MergeValue, false,
+		},
+	}
+	for _, test := range errorTestCases {
+		m := newExisting()
+		err := test.merge(m, test.input, WithPreserveExistingTypes(test.safeConversions))
+		assertError(t, err, test.parserErrorTestCase)
+	}
+}
+
+func Test_Parser_Splits_Units(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a binary suffix", "size=2Mi",
+			map[string]interface{}{
+				"size": Quantity{Value: 2, Unit: "Mi"},
+			},
+		),
+		newParserTestCase(
+			"a duration", "timeout=500ms",
+			map[string]interface{}{
+				"timeout": Quantity{Value: 500, Unit: "ms"},
+			},
+		),
+		newParserTestCase(
+			"a unit with a symbol", "t=-1.5°C",
+			map[string]interface{}{
+				"t": "-1.5°C",
+			},
+		),
+		newParserTestCase(
+			"an arbitrary unit", "d=-1.5km",
+			map[string]interface{}{
+				"d": Quantity{Value: -1.5, Unit: "km"},
+			},
+		),
+		newParserTestCase(
+			"a bare number", "n=42",
+			map[string]interface{}{
+				"n": int64(42),
+			},
+		),
+		newParserTestCase(
+			"a non-quantity string", "name=bob",
+			map[string]interface{}{
+				"name": "bob",
+			},
+		),
+		newParserTestCase(
+			"a unit followed by digits", "v=10k8s",
+			map[string]interface{}{
+				"v": "10k8s",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithUnitSplitting())
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Normalizes_Strings(t *testing.T) {
+	normalizer := func(s string) string {
+		return strings.ToLower(strings.TrimSpace(s))
+	}
+	input := "name= Bob ,a.b[0]=HELLO,city=New York,n=10,flag=true,empty=null"
+	testCases := []struct {
+		parserTestCase
+		merge func(map[string]interface{}, string, ...Option) error
+	}{
+		{
+			newParserTestCase("normalizing converted values", input,
+				map[string]interface{}{
+					"name": "bob",
+					"a": map[string]interface{}{
+						"b": []interface{}{"hello"},
+					},
+					"city":  "new york",
+					"n":     int64(10),
+					"flag":  true,
+					"empty": nil,
+				},
+			),
+			MergeValue,
+		},
+		{
+			newParserTestCase("normalizing strings", "name= Bob ,n=10",
+				map[string]interface{}{
+					"name": "bob",
+					"n":    "10",
+				},
+			),
+			MergeString,
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			if err := test.merge(m, part, WithStringNormalizer(normalizer)); err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test.parserTestCase, m)
+	}
+
+	test := newParserTestCase("normalizing split values", "a=X;5; Y",
+		map[string]interface{}{
+			"a": []interface{}{"x", int64(5), "y"},
+		},
+	)
+	m := map[string]interface{}{}
+	err := MergeValue(m, test.input, WithStringNormalizer(normalizer), WithGlobalValueSplit(';'))
+	assertNoError(t, err, test, m)
+}
+
+func Test_Parser_Folds_Block_Scalars(t *testing.T) {
+	input := "text=\"\n  first line\n    second line\n\n\n  next paragraph\n\""
+	testCases := []struct {
+		parserTestCase
+		fold, stripIndent bool
+	}{
+		{
+			newParserTestCase("a folded value with indentation", input,
+				map[string]interface{}{
+					"text": "  first line     second line\n  next paragraph",
+				},
+			),
+			true, false,
+		},
+		{
+			newParserTestCase("a folded value with the indentation stripped", input,
+				map[string]interface{}{
+					"text": "first line   second line\nnext paragraph",
+				},
+			),
+			true, true,
+		},
+		{
+			newParserTestCase("a literal value with the indentation stripped", input,
+				map[string]interface{}{
+					"text": "\nfirst line\n  second line\n\n\nnext paragraph\n",
+				},
+			),
+			false, true,
+		},
+		{
+			newParserTestCase("a literal value kept intact", input,
+				map[string]interface{}{
+					"text": "\n  first line\n    second line\n\n\n  next paragraph\n",
+				},
+			),
+			false, false,
+		},
+		{
+			newParserTestCase("a single line value", "text=\"  a  b \"",
+				map[string]interface{}{
+					"text": "  a  b ",
+				},
+			),
+			true, true,
+		},
+		{
+			newParserTestCase("an escaped multi-line value", "text=\\n a\\n\\n b",
+				map[string]interface{}{
+					"text": "a\nb",
+				},
+			),
+			true, true,
+		},
+	}
+	for _, test := range testCases {
+		for _, merge := range []func(map[string]interface{}, string, ...Option) error{MergeValue, MergeString} {
+			m := map[string]interface{}{}
+			err := merge(m, test.input, WithBlockScalarFolding(test.fold, test.stripIndent))
+			assertNoError(t, err, test.parserTestCase, m)
+		}
+	}
+}
+
+func Test_Parser_Parses_Matrices(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a 2x2 matrix", "m=[[5,2],[3,4]]",
+			map[string]interface{}{
+				"m": []interface{}{
+					[]interface{}{int64(5), int64(2)},
+					[]interface{}{int64(3), int64(4)},
+				},
+			},
+		),
+		newParserTestCase(
+			"a ragged matrix with spaces", "m=[ [1.5], [true, x, null] ,[] ]",
+			map[string]interface{}{
+				"m": []interface{}{
+					[]interface{}{1.5},
+					[]interface{}{true, "x", nil},
+					[]interface{}{},
+				},
+			},
+		),
+		newParserTestCase(
+			"an empty matrix", "m=[[]]",
+			map[string]interface{}{
+				"m": []interface{}{
+					[]interface{}{},
+				},
+			},
+		),
+		newParserTestCase(
+			"a flat array", "m=[5,2]",
+			map[string]interface{}{
+				"m": []interface{}{int64(5), int64(2)},
+			},
+		),
+		newParserTestCase(
+			"a value not starting with a bracket", "m=1\\,2]",
+			map[string]interface{}{
+				"m": "1,2]",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithMatrixValues())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unclosed row", "m=[[1,2],[3",
+			"unable to parse \"m=[[1,2],[3\", invalid matrix \"[[1,2],[3\", expecting ']'",
+		),
+		newParserErrorTestCase(
+			"a missing separator", "m=[[1][2]]",
+			"unable to parse \"m=[[1][2]]\", invalid matrix \"[[1][2]]\", expecting ',' or ']'",
+		),
+		newParserErrorTestCase(
+			"a trailing text", "m=[1]x",
+			"unable to parse \"m=[1]x\", invalid matrix \"[1]x\", unexpected \"x\" after the matrix",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithMatrixValues())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Expands_Index_Ranges(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"an index range", "foo[0:2]=x",
+			map[string]interface{}{
+				"foo": []interface{}{"x", "x", "x"},
+			},
+		),
+		newParserTestCase(
+			"a single index range", "foo[1:1]=x",
+			map[string]interface{}{
+				"foo": []interface{}{nil, "x"},
+			},
+		),
+		newParserTestCase(
+			"a range of maps", "foo[1:2].a=x",
+			map[string]interface{}{
+				"foo": []interface{}{
+					nil,
+					map[string]interface{}{"a": "x"},
+					map[string]interface{}{"a": "x"},
+				},
+			},
+		),
+		newParserTestCase(
+			"nested ranges", "foo[0:1][1:2]=x",
+			map[string]interface{}{
+				"foo": []interface{}{
+					[]interface{}{nil, "x", "x"},
+					[]interface{}{nil, "x", "x"},
+				},
+			},
+		),
+		newParserTestCase(
+			"a range in a range of maps", "foo[0:1].a[0:1]=x",
+			map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{"a": []interface{}{"x", "x"}},
+					map[string]interface{}{"a": []interface{}{"x", "x"}},
+				},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithIndexRanges())
+		assertNoError(t, err, test, m)
+	}
+
+	test := newParserTestCase(
+		"a range over an existing array", "foo[1:3]=x",
+		map[string]interface{}{
+			"foo": []interface{}{"a", "x", "x", "x"},
+		},
+	)
+	m := map[string]interface{}{
+		"foo": []interface{}{"a", "b"},
+	}
+	assertNoError(t, MergeValue(m, test.input, WithIndexRanges()), test, m)
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a reversed range", "foo[2:0]=x",
+			"unable to parse \"foo[2:0]=x\", reversed array index range [2:0]",
+		),
+		newParserErrorTestCase(
+			"a negative first index", "foo[-1:2]=x",
+			"unable to parse \"foo[-1:2]=x\", negative array index range [-1:2]",
+		),
+		newParserErrorTestCase(
+			"a negative last index", "foo[0:-1]=x",
+			"unable to parse \"foo[0:-1]=x\", in position 7 got unexpected character: U+002D '-', expecting an array index",
+		),
+	}
+	for _, test := range errorTestCases {
+		err := MergeValue(map[string]interface{}{}, test.input, WithIndexRanges())
+		assertError(t, err, test)
+	}
+
+	errorTestCases = []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a negative first index over an existing array", "foo[-1:2]=x",
+			"unable to parse \"foo[-1:2]=x\", negative array index range [-1:2]",
+		),
+		newParserErrorTestCase(
+			"a negative range over an existing array", "foo[-3:2]=x",
+			"unable to parse \"foo[-3:2]=x\", negative array index range [-3:2]",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{
+			"foo": []interface{}{"a", "b", "c"},
+		}
+		assertError(t, MergeValue(m, test.input, WithIndexRanges()), test)
+	}
+
+	errorTest := newParserErrorTestCase(
+		"a range without the option", "foo[0:2]=x",
+		"unable to parse \"foo[0:2]=x\", in position 6 got unexpected character: U+003A ':', expecting ']'",
+	)
+	assertError(t, MergeValue(map[string]interface{}{}, errorTest.input), errorTest)
+}
+
+func Test_Parser_Splits_Shell_Words(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"quoted words", `cmd=echo "hello world" 'it''s' foo`,
+			map[string]interface{}{
+				"cmd": []interface{}{"echo", "hello world", "its", "foo"},
+			},
+		),
+		newParserTestCase(
+			"escaped spaces and quotes", `cmd=ls my\ dir "say \"hi\"" 'a\b'`,
+			map[string]interface{}{
+				"cmd": []interface{}{"ls", "my dir", `say "hi"`, `a\b`},
+			},
+		),
+		newParserTestCase(
+			"a single unquoted word", "cmd=true",
+			map[string]interface{}{
+				"cmd": []interface{}{"true"},
+			},
+		),
+		newParserTestCase(
+			"an empty quoted word", `cmd=a "" b`,
+			map[string]interface{}{
+				"cmd": []interface{}{"a", "", "b"},
+			},
+		),
+		newParserTestCase(
+			"a value at another path", "args=a b",
+			map[string]interface{}{
+				"args": "a b",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithShellSplit("cmd"))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unterminated quote", `cmd=echo "hi`,
+			`unable to parse "cmd=echo "hi", unable to split "echo "hi", unterminated quote`,
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithShellSplit("cmd"))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Keeps_Arrays_Homogeneous(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a homogeneous string array", "a[0]=x,a[1]=y,a[3]=z",
+			map[string]interface{}{
+				"a": []interface{}{"x", "y", nil, "z"},
+			},
+		),
+		newParserTestCase(
+			"null elements", "a[0]=5,a[1]=null,a[2]=2",
+			map[string]interface{}{
+				"a": []interface{}{int64(5), nil, int64(2)},
+			},
+		),
+		newParserTestCase(
+			"overriding the only element", "a[0]=5,a[0]=x",
+			map[string]interface{}{
+				"a": []interface{}{"x"},
+			},
+		),
+		newParserTestCase(
+			"maps in an array", "a[0].b=5,a[1].b=x",
+			map[string]interface{}{
+				"a": []interface{}{
+					map[string]interface{}{"b": int64(5)},
+					map[string]interface{}{"b": "x"},
+				},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			if err := MergeValue(m, part, WithHomogeneousArrays()); err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a mixed int and string array", "a[2]=x",
+			"unable to parse \"a[2]=x\", value of type string at path \"a[2]\" does not match the type int64 of the other elements",
+		),
+		newParserErrorTestCase(
+			"a mixed int and float array", "a[0]=1.5",
+			"unable to parse \"a[0]=1.5\", value of type float64 at path \"a[0]\" does not match the type int64 of the other elements",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{
+			"a": []interface{}{int64(5), int64(2)},
+		}
+		err := MergeValue(m, test.input, WithHomogeneousArrays())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Accepts_Bare_Key_Booleans(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a bare key", "debug",
+			map[string]interface{}{
+				"debug": true,
+			},
+		),
+		newParserTestCase(
+			"a negated bare key", "!debug",
+			map[string]interface{}{
+				"debug": false,
+			},
+		),
+		newParserTestCase(
+			"a negated nested bare key", "!foo[1].enabled",
+			map[string]interface{}{
+				"foo": []interface{}{
+					nil,
+					map[string]interface{}{
+						"enabled": false,
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"an escaped leading negation", "\\!important",
+			map[string]interface{}{
+				"!important": true,
+			},
+		),
+		newParserTestCase(
+			"a negation inside a key", "a!b=x",
+			map[string]interface{}{
+				"a!b": "x",
+			},
+		),
+		newParserTestCase(
+			"several bare keys", "debug,!verbose,level=2",
+			map[string]interface{}{
+				"debug":   true,
+				"verbose": false,
+				"level":   int64(2),
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithBareKeyBooleans())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a negated assignment", "!debug=true",
+			"unable to parse \"!debug=true\", negated key \"debug\" cannot be assigned a value",
+		),
+		newParserErrorTestCase(
+			"a negation only", "!",
+			"unable to parse \"!\", unexpected end, expecting a map key",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithBareKeyBooleans())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Trims_Trailing_Nils(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a trailing null", "foo[0]=a,foo[1]=b,foo[1]=null",
+			map[string]interface{}{
+				"foo": []interface{}{"a"},
+			},
+		),
+		newParserTestCase(
+			"a single null", "foo[0]=null",
+			map[string]interface{}{
+				"foo": []interface{}{},
+			},
+		),
+		newParserTestCase(
+			"interior gaps", "foo[0]=a,foo[3]=d,foo[5]=null",
+			map[string]interface{}{
+				"foo": []interface{}{"a", nil, nil, "d"},
+			},
+		),
+		newParserTestCase(
+			"trailing gaps", "foo[0]=a,foo[3]=d,foo[3]=null",
+			map[string]interface{}{
+				"foo": []interface{}{"a"},
+			},
+		),
+		newParserTestCase(
+			"an interior null", "foo[0]=a,foo[1]=b,foo[0]=null",
+			map[string]interface{}{
+				"foo": []interface{}{nil, "b"},
+			},
+		),
+		newParserTestCase(
+			"a nested array", "foo.bar[0][1]=null",
+			map[string]interface{}{
+				"foo": map[string]interface{}{
+					"bar": []interface{}{
+						[]interface{}{},
+					},
+				},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			if err := MergeValue(m, part, WithTrimTrailingNils()); err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test, m)
+	}
+}
+
+func Test_Parser_Falls_Back_For_Bare_Words(t *testing.T) {
+	testCases := []struct {
+		parserTestCase
+		defaultKey string
+	}{
+		{
+			newParserTestCase(
+				"a bare word as a key", "debug",
+				map[string]interface{}{
+					"debug": true,
+				},
+			),
+			"",
+		},
+		{
+			newParserTestCase(
+				"a bare word with an escaped dot", "example\\.com",
+				map[string]interface{}{
+					"example.com": true,
+				},
+			),
+			"",
+		},
+		{
+			newParserTestCase(
+				"a bare word assigned to the default key", "hello",
+				map[string]interface{}{
+					"msg": "hello",
+				},
+			),
+			"msg",
+		},
+		{
+			newParserTestCase(
+				"bare words separated by commas", "debug,verbose",
+				map[string]interface{}{
+					"debug":   true,
+					"verbose": true,
+				},
+			),
+			"",
+		},
+		{
+			newParserTestCase(
+				"a bare word with a comma assigned to the default key", "hello, world",
+				map[string]interface{}{
+					"msg": "hello, world",
+				},
+			),
+			"msg",
+		},
+		{
+			newParserTestCase(
+				"a bare word converted at the default key", "42",
+				map[string]interface{}{
+					"log.level": int64(42),
+				},
+			),
+			"log.level",
+		},
+		{
+			newParserTestCase(
+				"a path", "a.b=c",
+				map[string]interface{}{
+					"a": map[string]interface{}{"b": "c"},
+				},
+			),
+			"msg",
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithBareWordFallback(test.defaultKey))
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a path without an assignment", "a.b",
+			"unable to parse \"a.b\", unexpected end, expecting '.', '=' or '['",
+		),
+		newParserErrorTestCase(
+			"an empty input", "",
+			"unable to parse \"\", unexpected end, expecting a map key",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithBareWordFallback("msg"))
+		assertError(t, err, test)
+	}
+	errorTest := newParserErrorTestCase(
+		"a bare word without the option", "debug",
+		"unable to parse \"debug\", unexpected end, expecting '.', '=' or '['",
+	)
+	assertError(t, MergeValue(map[string]interface{}{}, errorTest.input), errorTest)
+}
+
+func Test_Parser_Resolves_Index_Names(t *testing.T) {
+	names := map[string]int{
+		"extract":   0,
+		"transform": 1,
+		"load":      2,
+		"broken":    -1,
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"an index name", "pipeline[load]=x",
+			map[string]interface{}{
+				"pipeline": []interface{}{nil, nil, "x"},
+			},
+		),
+		newParserTestCase(
+			"an index name followed by a key", "pipeline[transform].cmd=sed",
+			map[string]interface{}{
+				"pipeline": []interface{}{
+					nil,
+					map[string]interface{}{"cmd": "sed"},
+				},
+			},
+		),
+		newParserTestCase(
+			"a numeric index", "pipeline[0]=x",
+			map[string]interface{}{
+				"pipeline": []interface{}{"x"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithIndexNames(names))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unknown name", "pipeline[publish]=x",
+			"unable to parse \"pipeline[publish]=x\", unknown index name \"publish\"",
+		),
+		newParserErrorTestCase(
+			"a name of a negative index", "pipeline[broken]=x",
+			"unable to parse \"pipeline[broken]=x\", unknown index name \"broken\"",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithIndexNames(names))
+		assertError(t, err, test)
+	}
+
+	test := newParserTestCase(
+		"a duration key with index names", "ttl[1h].on=true",
+		map[string]interface{}{
+			"ttl": map[string]interface{}{
+				"1h0m0s": map[string]interface{}{"on": true},
+			},
+		},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input, WithIndexNames(names), WithDurationKeys()), test, m)
+}
+
+func Test_Parser_Forces_Types_With_Sigils(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"an int sigil", "count=#08",
+			map[string]interface{}{"count": int64(8)},
+		),
+		newParserTestCase(
+			"a float sigil", "ratio=~2",
+			map[string]interface{}{"ratio": float64(2)},
+		),
+		newParserTestCase(
+			"a bool sigil", "flag=?1",
+			map[string]interface{}{"flag": true},
+		),
+		newParserTestCase(
+			"a string sigil", "version=$1.10",
+			map[string]interface{}{"version": "1.10"},
+		),
+		newParserTestCase(
+			"an escaped sigil", "tag=\\#08",
+			map[string]interface{}{"tag": "#08"},
+		),
+		newParserTestCase(
+			"an escaped sigil with a convertible rest", "tag=\\$",
+			map[string]interface{}{"tag": "$"},
+		),
+		newParserTestCase(
+			"no sigil", "count=08",
+			map[string]interface{}{"count": int64(8)},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithValueSigils())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"not an int", "count=#x",
+			"unable to parse \"count=#x\", value \"x\" at path \"count\" is not an integer",
+		),
+		newParserErrorTestCase(
+			"not a float", "ratio=~x",
+			"unable to parse \"ratio=~x\", value \"x\" at path \"ratio\" is not a float",
+		),
+		newParserErrorTestCase(
+			"not a bool", "flag=?x",
+			"unable to parse \"flag=?x\", value \"x\" at path \"flag\" is not a boolean",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithValueSigils())
+		assertError(t, err, test)
+	}
+
+	test := newParserTestCase(
+		"sigils disabled", "count=#08",
+		map[string]interface{}{"count": "#08"},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input), test, m)
+}
+
+func Test_Parser_Uses_Custom_Syntax(t *testing.T) {
+	testCases := []struct {
+		parserTestCase
+		syntax Syntax
+	}{
+		{
+			newParserTestCase("a key separator", "db.host/port=5",
+				map[string]interface{}{
+					"db.host": map[string]interface{}{"port": int64(5)},
+				},
+			),
+			Syntax{KeySeparator: '/'},
+		},
+		{
+			newParserTestCase("an escaped default character", "a\\.b/c=5",
+				map[string]interface{}{
+					"a.b": map[string]interface{}{"c": int64(5)},
+				},
+			),
+			Syntax{KeySeparator: '/'},
+		},
+		{
+			newParserTestCase("an assignment operator", "a=b|x=y",
+				map[string]interface{}{
+					"a=b": "x=y",
+				},
+			),
+			Syntax{Assignment: '|'},
+		},
+		{
+			newParserTestCase("index brackets", "a[0](1)=x,b()=y",
+				map[string]interface{}{
+					"a[0]": []interface{}{nil, "x"},
+					"b":    []interface{}{"y"},
+				},
+			),
+			Syntax{IndexStart: '(', IndexFinish: ')'},
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithSyntax(test.syntax))
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+
+	test := newParserTestCase("a query string", "a.b/c=5&d=x%7Cy",
+		map[string]interface{}{
+			"a.b": map[string]interface{}{"c": int64(5)},
+			"d":   "x|y",
+		},
+	)
+	m := map[string]interface{}{}
+	err := MergeQuery(m, test.input, WithSyntax(Syntax{KeySeparator: '/', Assignment: '|'}))
+	assertNoError(t, err, test, m)
+
+	errorTestCases := []struct {
+		parserErrorTestCase
+		syntax Syntax
+	}{
+		{
+			newParserErrorTestCase("a missing assignment", "a.b/c",
+				"unable to parse \"a.b/c\", unexpected end, expecting '/', '=' or '['",
+			),
+			Syntax{KeySeparator: '/'},
+		},
+		{
+			newParserErrorTestCase("a repeated character", "a=1",
+				"unable to parse \"a=1\", repeated syntax character '='",
+			),
+			Syntax{KeySeparator: '='},
+		},
+		{
+			newParserErrorTestCase("an invalid character", "a=1",
+				"unable to parse \"a=1\", invalid syntax character ','",
+			),
+			Syntax{Assignment: ','},
+		},
+	}
+	for _, test := range errorTestCases {
+		err := MergeValue(map[string]interface{}{}, test.input, WithSyntax(test.syntax))
+		assertError(t, err, test.parserErrorTestCase)
+	}
+}
+
+func Test_Parser_Compiles_Regexp_Values(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a valid pattern", "match=re:^foo.*$",
+			map[string]interface{}{"match": regexp.MustCompile("^foo.*$")},
+		),
+		newParserTestCase(
+			"a pattern with escapes", "match=re:^\\d+\\.\\w$",
+			map[string]interface{}{"match": regexp.MustCompile("^\\d+\\.\\w$")},
+		),
+		newParserTestCase(
+			"an escaped prefix", "match=\\re:^foo",
+			map[string]interface{}{"match": "re:^foo"},
+		),
+		newParserTestCase(
+			"an unprefixed value", "match=^foo.*$",
+			map[string]interface{}{"match": "^foo.*$"},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithRegexpValues())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTest := newParserErrorTestCase(
+		"an invalid pattern", "match=re:a(b",
+		"unable to parse \"match=re:a(b\", unable to compile \"re:a(b\", error parsing regexp: missing closing ): `a(b`",
+	)
+	err := MergeValue(map[string]interface{}{}, errorTest.input, WithRegexpValues())
+	assertError(t, err, errorTest)
+
+	test := newParserTestCase(
+		"regexp values disabled", "match=re:^foo",
+		map[string]interface{}{"match": "re:^foo"},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input), test, m)
+}
+
+func Test_Parser_Requires_Exact_Depth(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a path of the required depth", "db.host=localhost",
+			map[string]interface{}{
+				"db": map[string]interface{}{"host": "localhost"},
+			},
+		),
+		newParserTestCase(
+			"an array index of the required depth", "hosts[1]=b",
+			map[string]interface{}{
+				"hosts": []interface{}{nil, "b"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithExactDepth(2))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a shallower path", "host=localhost",
+			"unable to parse \"host=localhost\", path depth 1 is less than the required 2",
+		),
+		newParserErrorTestCase(
+			"a deeper path", "db.primary.host=localhost",
+			"unable to parse \"db.primary.host=localhost\", path depth exceeds the required 2",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithExactDepth(2))
+		assertError(t, err, test)
+		if len(m) != 0 {
+			t.Errorf("In the case of %s expected the map to stay empty, got %v", test.desc, m)
+		}
+	}
+}
+
+func Test_Parser_Parses_Ordered_Values(t *testing.T) {
+	m := map[string]interface{}{}
+	input := "info={title=API; version=2; desc.text=a\\;b; servers[1]={url=x; port=80}}"
+	if err := MergeValue(m, input, WithOrderedValues()); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	info, ok := m["info"].(*OrderedMap)
+	if !ok {
+		t.Fatalf("Expected an ordered map, got %T", m["info"])
+	}
+	expected := []string{"title", "version", "desc", "servers"}
+	if !reflect.DeepEqual(info.Keys(), expected) {
+		t.Errorf("Expected keys %v, got %v", expected, info.Keys())
+	}
+	if v, _ := info.Get("version"); v != int64(2) {
+		t.Errorf("Expected version 2, got %#v", v)
+	}
+	desc, _ := info.Get("desc")
+	if text, _ := desc.(*OrderedMap).Get("text"); text != "a;b" {
+		t.Errorf("Expected text \"a;b\", got %#v", text)
+	}
+	servers, _ := info.Get("servers")
+	server := servers.([]interface{})[1].(*OrderedMap)
+	expected = []string{"url", "port"}
+	if !reflect.DeepEqual(server.Keys(), expected) {
+		t.Errorf("Expected keys %v, got %v", expected, server.Keys())
+	}
+
+	test := newParserTestCase(
+		"an empty braced value", "info={}",
+		map[string]interface{}{"info": NewOrderedMap()},
+	)
+	m = map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input, WithOrderedValues()), test, m)
+
+	errorTest := newParserErrorTestCase(
+		"an invalid field", "info={a=1; b}",
+		"unable to parse \"info={a=1; b}\", unable to parse \"b\", unexpected end, expecting '.', '=' or '['",
+	)
+	m = map[string]interface{}{}
+	assertError(t, MergeValue(m, errorTest.input, WithOrderedValues()), errorTest)
+
+	test = newParserTestCase(
+		"ordered values disabled", "info={a=1}",
+		map[string]interface{}{"info": []interface{}{"a=1"}},
+	)
+	m = map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input), test, m)
+}
+
+func Test_Parser_Substitutes_Aliases_Of_Anchors(t *testing.T) {
+	anchors := map[string]interface{}{}
+	opts := []Option{WithAnchors(anchors), WithNestedValues()}
+	test := newParserTestCase(
+		"an anchor and its aliases", "base=&b nested:host=x,prod=*b,prod.host=y,ports=&p 80,alt=*p",
+		map[string]interface{}{
+			"base":  map[string]interface{}{"host": "x"},
+			"prod":  map[string]interface{}{"host": "y"},
+			"ports": int64(80),
+			"alt":   int64(80),
+		},
+	)
+	m := map[string]interface{}{}
+	for _, part := range strings.Split(test.input, ",") {
+		if err := MergeValue(m, part, opts...); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+	assertNoError(t, nil, test, m)
+
+	m = map[string]interface{}{}
+	input := "base=&b {host=x; port=80}"
+	if err := MergeValue(m, input, WithAnchors(anchors), WithOrderedValues()); err != nil {
+		t.Fatalf("Expected success for \"%s\", got %v", input, err)
+	}
+	if err := MergeValue(m, "prod=*b", WithAnchors(anchors)); err != nil {
+		t.Fatalf("Expected success for \"prod=*b\", got %v", err)
+	}
+	if !reflect.DeepEqual(m["prod"], m["base"]) || m["prod"] == m["base"] {
+		t.Errorf("Expected a copy of %v, got %v", m["base"], m["prod"])
+	}
+
+	host := NewOrderedMap()
+	host.Set("host", "x")
+	documented := []struct {
+		parserTestCase
+		opts []Option
+	}{
+		{
+			newParserTestCase(
+				"an anchored ordered map", "base=&b {host=x},prod=*b",
+				map[string]interface{}{
+					"base": host,
+					"prod": host,
+				},
+			),
+			[]Option{WithAnchors(map[string]interface{}{}), WithOrderedValues()},
+		},
+		{
+			newParserTestCase(
+				"an anchored brace list", "base=&b {host=x},prod=*b",
+				map[string]interface{}{
+					"base": []interface{}{"host=x"},
+					"prod": []interface{}{"host=x"},
+				},
+			),
+			[]Option{WithAnchors(map[string]interface{}{})},
+		},
+	}
+	for _, test := range documented {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, test.opts...)
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"not an alias", "files=*.yaml",
+			map[string]interface{}{"files": "*.yaml"},
+		),
+		newParserTestCase(
+			"not an anchor", "q=&x",
+			map[string]interface{}{"q": "&x"},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithAnchors(anchors))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTest := newParserErrorTestCase(
+		"an undefined alias", "prod=*missing",
+		"unable to parse \"prod=*missing\", undefined alias \"missing\"",
+	)
+	m = map[string]interface{}{}
+	assertError(t, MergeValue(m, errorTest.input, WithAnchors(anchors)), errorTest)
+}
+
+func Test_Parser_Resolves_File_Paths(t *testing.T) {
+	base := filepath.Join(string(filepath.Separator), "etc", "app")
+	absolute := filepath.Join(string(filepath.Separator), "var", "x.yaml")
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a relative path", "config=./sub/../x.yaml",
+			map[string]interface{}{
+				"config": filepath.Join(base, "x.yaml"),
+			},
+		),
+		newParserTestCase(
+			"a nested relative path", "config=sub//y.yaml",
+			map[string]interface{}{
+				"config": filepath.Join(base, "sub", "y.yaml"),
+			},
+		),
+		newParserTestCase(
+			"an absolute path", "config="+absolute,
+			map[string]interface{}{
+				"config": absolute,
+			},
+		),
+		newParserTestCase(
+			"a value at another path", "name=./x",
+			map[string]interface{}{
+				"name": "./x",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithBasePath(base, "config"))
+		assertNoError(t, err, test, m)
+	}
+}
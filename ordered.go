@@ -0,0 +1,253 @@
+package djson
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// OrderedMap is a map that preserves the order in which its keys were added.
+// Assigning a value to an existing key keeps the original position of the key.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewOrderedMap creates an empty ordered map.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{
+		values: map[string]interface{}{},
+	}
+}
+
+// Keys returns the keys of the map in the order they were added.
+func (m *OrderedMap) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Get returns the value stored under the key and whether the key is present.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	val, ok := m.values[key]
+	return val, ok
+}
+
+// Set stores the value under the key, adding the key at the end of the map
+// if it is not present yet.
+func (m *OrderedMap) Set(key string, val interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = val
+}
+
+// Delete removes the key from the map if it is present.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// MergeValueOrdered deserializes the input string like MergeValue does and
+// merges result to the ordered map provided. Nested maps are built as
+// ordered maps as well.
+func MergeValueOrdered(m *OrderedMap, str string, opts ...Option) error {
+	parser := newParser(str, opts)
+	parser.rightValueReader = parser.readRightValue
+	return parser.merge(newOrderedRootBuilder(m), str)
+}
+
+// MergeStringOrdered deserializes the input string like MergeString does and
+// merges result to the ordered map provided. Nested maps are built as
+// ordered maps as well.
+func MergeStringOrdered(m *OrderedMap, str string, opts ...Option) error {
+	parser := newParser(str, opts)
+	parser.rightValueReader = parser.readRightString
+	return parser.merge(newOrderedRootBuilder(m), str)
+}
+
+type orderedRootBuilder struct {
+	m *OrderedMap
+}
+
+func newOrderedRootBuilder(m *OrderedMap) *orderedRootBuilder {
+	return &orderedRootBuilder{
+		m: m,
+	}
+}
+
+func (b *orderedRootBuilder) newMapBuilder(key string) builder {
+	return &orderedMapBuilder{m: b.m, key: key, parent: b}
+}
+
+func (b *orderedRootBuilder) set(val interface{}) {
+	// Set nothing, map is passed by reference.
+}
+
+type orderedMapBuilder struct {
+	m      *OrderedMap
+	key    string
+	parent setter
+}
+
+func (b *orderedMapBuilder) newMapBuilder(key string) builder {
+	if v, ok := b.m.Get(b.key); ok {
+		if m, ok := v.(*OrderedMap); ok {
+			return &orderedMapBuilder{m: m, key: key, parent: b}
+		}
+	}
+	return &orderedMapBuilder{m: NewOrderedMap(), key: key, parent: b}
+}
+
+func (b *orderedMapBuilder) newArrayBuilder(index int) builder {
+	if v, ok := b.m.Get(b.key); ok {
+		if a, ok := v.([]interface{}); ok {
+			return newOrderedArrayBuilder(a, index, b)
+		}
+	}
+	return newOrderedArrayBuilder(nil, index, b)
+}
+
+func (b *orderedMapBuilder) get() (interface{}, bool) {
+	return b.m.Get(b.key)
+}
+
+func (b *orderedMapBuilder) set(val interface{}) {
+	b.m.Set(b.key, val)
+	b.parent.set(b.m)
+}
+
+func (b *orderedMapBuilder) delete() {
+	b.m.Delete(b.key)
+}
+
+// orderedArrayBuilder builds arrays the same way arrayBuilder does, but
+// creates ordered maps for the elements.
+type orderedArrayBuilder struct {
+	arrayBuilder
+}
+
+func newOrderedArrayBuilder(a []interface{}, index int, parent setter) *orderedArrayBuilder {
+	return &orderedArrayBuilder{
+		arrayBuilder{a: a, index: index, parent: parent},
+	}
+}
+
+func (b *orderedArrayBuilder) newMapBuilder(key string) builder {
+	if len(b.a) >= b.index+1 {
+		if m, ok := b.a[b.index].(*OrderedMap); ok {
+			return &orderedMapBuilder{m: m, key: key, parent: b}
+		}
+	}
+	return &orderedMapBuilder{m: NewOrderedMap(), key: key, parent: b}
+}
+
+func (b *orderedArrayBuilder) newArrayBuilder(index int) builder {
+	if len(b.a) >= b.index+1 {
+		if a, ok := b.a[b.index].([]interface{}); ok {
+			return newOrderedArrayBuilder(a, index, b)
+		}
+	}
+	return newOrderedArrayBuilder(nil, index, b)
+}
+
+// MarshalTOML renders the ordered map as TOML-like assignments following the
+// order of the keys. Nested maps are rendered as dotted keys, arrays as
+// inline arrays and maps inside arrays as inline tables. Nil values cannot be
+// represented in TOML and fail the rendering.
+func MarshalTOML(m *OrderedMap) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := marshalTOMLMap(&buf, "", m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalTOMLMap(buf *bytes.Buffer, prefix string, m *OrderedMap) error {
+	for _, key := range m.keys {
+		path := prefix + tomlKey(key)
+		if nested, ok := m.values[key].(*OrderedMap); ok && nested.Len() > 0 {
+			if err := marshalTOMLMap(buf, path+".", nested); err != nil {
+				return err
+			}
+			continue
+		}
+		val, err := tomlValue(m.values[key])
+		if err != nil {
+			return fmt.Errorf("unable to render \"%s\", %v", path, err)
+		}
+		fmt.Fprintf(buf, "%s = %s\n", path, val)
+	}
+	return nil
+}
+
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if tomlBareKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+func tomlValue(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case nil:
+		return "", fmt.Errorf("null values are not supported")
+	case string:
+		return strconv.Quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case []interface{}:
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			s, err := tomlValue(e)
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(s)
+		}
+		buf.WriteString("]")
+		return buf.String(), nil
+	case *OrderedMap:
+		var buf bytes.Buffer
+		buf.WriteString("{")
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			s, err := tomlValue(v.values[key])
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&buf, " %s = %s", tomlKey(key), s)
+		}
+		if v.Len() > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString("}")
+		return buf.String(), nil
+	default:
+		return fmt.Sprintf("%q", fmt.Sprint(v)), nil
+	}
+}
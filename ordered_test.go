@@ -0,0 +1,104 @@
+package djson
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_OrderedMap_Preserves_Key_Order(t *testing.T) {
+	input := "b=1,a.z=x,a.y[0].q=y,a.y[0].p=z,c=3,b=4,a.z=w"
+	m := NewOrderedMap()
+	for _, part := range strings.Split(input, ",") {
+		if err := MergeValueOrdered(m, part); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+
+	assertKeys(t, m.Keys(), []string{"b", "a", "c"})
+	if b, _ := m.Get("b"); b != int64(4) {
+		t.Errorf("Expected b to be overridden with 4, got %v", b)
+	}
+
+	a, _ := m.Get("a")
+	nested, ok := a.(*OrderedMap)
+	if !ok {
+		t.Fatalf("Expected a nested ordered map, got %T", a)
+	}
+	assertKeys(t, nested.Keys(), []string{"z", "y"})
+
+	y, _ := nested.Get("y")
+	arr, ok := y.([]interface{})
+	if !ok || len(arr) != 1 {
+		t.Fatalf("Expected an array with a single element, got %v", y)
+	}
+	elem, ok := arr[0].(*OrderedMap)
+	if !ok {
+		t.Fatalf("Expected an ordered map in the array, got %T", arr[0])
+	}
+	assertKeys(t, elem.Keys(), []string{"q", "p"})
+}
+
+func Test_OrderedMap_Deletes_Keys(t *testing.T) {
+	m := NewOrderedMap()
+	for _, part := range []string{"a=1,b.x=2,b.y=3,c=4", "a-,b.x-,missing-"} {
+		if err := MergeValueOrdered(m, part); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+	assertKeys(t, m.Keys(), []string{"b", "c"})
+	b, _ := m.Get("b")
+	assertKeys(t, b.(*OrderedMap).Keys(), []string{"y"})
+}
+
+func Test_MergeStringOrdered_Keeps_Strings(t *testing.T) {
+	m := NewOrderedMap()
+	if err := MergeStringOrdered(m, "foo.bar=true"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	foo, _ := m.Get("foo")
+	if bar, _ := foo.(*OrderedMap).Get("bar"); bar != "true" {
+		t.Errorf("Expected string \"true\", got %v", bar)
+	}
+}
+
+func assertKeys(t *testing.T, keys, expected []string) {
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+}
+
+func Test_MarshalTOML(t *testing.T) {
+	input := "title=test,server.port=8080,server.host name=local,tags[1]=b,tags[0]=a,users[0].name=bob,ratio=0.5,debug=true"
+	m := NewOrderedMap()
+	for _, part := range strings.Split(input, ",") {
+		if err := MergeValueOrdered(m, part); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", part, err)
+		}
+	}
+
+	expected := `title = "test"
+server.port = 8080
+server."host name" = "local"
+tags = ["a", "b"]
+users = [{ name = "bob" }]
+ratio = 0.5
+debug = true
+`
+	b, err := MarshalTOML(m)
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if string(b) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, b)
+	}
+
+	if err := MergeValueOrdered(m, "server.port=null"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	_, err = MarshalTOML(m)
+	expectedErr := "unable to render \"server.port\", null values are not supported"
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected error \"%s\", got %v", expectedErr, err)
+	}
+}
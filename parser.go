@@ -3,38 +3,112 @@ package djson
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // MergeValue deserializes the input string and merges result to the map provided.
-func MergeValue(m map[string]interface{}, str string) error {
-	parser := &parser{
-		lex: newLex(str),
-	}
+func MergeValue(m map[string]interface{}, str string, opts ...Option) error {
+	parser := newParser(str, opts)
 	parser.rightValueReader = parser.readRightValue
-	return parser.merge(m, str)
+	return parser.merge(newRootBuilder(m), str)
 }
 
 // MergeString deserializes the input string and merges result to the map provided.
-func MergeString(m map[string]interface{}, str string) error {
-	parser := &parser{
-		lex: newLex(str),
-	}
+func MergeString(m map[string]interface{}, str string, opts ...Option) error {
+	parser := newParser(str, opts)
 	parser.rightValueReader = parser.readRightString
-	return parser.merge(m, str)
+	return parser.merge(newRootBuilder(m), str)
+}
+
+// Parse deserializes the input string like MergeValue does and returns the
+// result in a new map, or nil if the parsing fails.
+func Parse(str string, opts ...Option) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if err := MergeValue(m, str, opts...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseString deserializes the input string like MergeString does and
+// returns the result in a new map, or nil if the parsing fails.
+func ParseString(str string, opts ...Option) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if err := MergeString(m, str, opts...); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ParseScalar converts the input string on its own the way MergeValue
+// converts values, with no key in front, so "42" returns int64(42), "\"42\""
+// returns the string "42" and "a\\nb" a string of two lines. Commas belong to
+// the scalar rather than separating assignments. The options declared per
+// path apply to the scalar at the empty path, e.g.
+// WithTimeLayouts(map[string]string{"": "2006-01-02"}) parses dates.
+func ParseScalar(str string, opts ...Option) (interface{}, error) {
+	o := newOptions(opts)
+	p := &parser{opts: o, lex: newScalarLex(str, o)}
+	val, err := p.readRightValue()
+	if err == nil {
+		if tok := p.nextToken(); tok.TokenType != tokenEnd {
+			err = tokenToError(tok)
+		}
+	}
+	if err != nil {
+		p.lex.close()
+		return nil, fmt.Errorf("unable to parse \"%s\", %v", str, err)
+	}
+	return val, nil
 }
 
 type parser struct {
 	lex              lexer
+	opts             options
+	path             string // The path of the current assignment
+	depth            int    // The depth of the current assignment
+	indexed          bool   // Whether the last element of the path is an index
+	newKeys          int    // The number of map keys created
+	gaps             int    // The number of array gaps filled with nil
+	negated          bool   // Whether the bare key is negated
+	assignments      int    // The number of assignments read
+	backup           *token // The token read ahead, if any
 	rightValueReader func() (interface{}, error)
 }
 
-func (p *parser) merge(m map[string]interface{}, str string) error {
-	builder := newRootBuilder(m)
-	// Expecting a map at the top level
-	err := p.readMap(builder)
+func newParser(str string, opts []Option) *parser {
+	o := newOptions(opts)
+	if o.bareWordFallback && isBareWord(str, o.syntax.stopChars()) {
+		if o.fallbackKey == "" {
+			o.bareKeyBooleans = true
+		} else {
+			str = escapeKeyChars(o.fallbackKey, o.syntax.stopChars()) +
+				string(o.syntax.Assignment) + escapeCommas(str)
+		}
+	}
+	return &parser{
+		lex:  newLex(str, o),
+		opts: o,
+	}
+}
+
+func (p *parser) merge(builder mapBuilderFactory, str string) error {
+	err := p.opts.syntax.check()
+	if err == nil && p.opts.valueSeparator == ',' {
+		// Commas already separate the assignments
+		err = errors.New("invalid value separator ','")
+	}
+	if err == nil && p.opts.balanceCheck {
+		err = checkBalance(str)
+	}
+	if err == nil {
+		err = p.readAssignments(builder)
+	}
 	if err != nil {
-		p.lex.drain()
+		p.lex.close()
 		p.lex = nil
 		return fmt.Errorf("unable to parse \"%s\", %v", str, err)
 	}
@@ -43,18 +117,68 @@ func (p *parser) merge(m map[string]interface{}, str string) error {
 }
 
 func (p *parser) nextToken() token {
+	if tok := p.backup; tok != nil {
+		p.backup = nil
+		return *tok
+	}
 	return p.lex.nextToken()
 }
 
+// Put the token back to be read again by nextToken.
+func (p *parser) unreadToken(tok token) {
+	p.backup = &tok
+}
+
+// Read the assignments separated by commas, starting each one from the
+// beginning of the path.
+func (p *parser) readAssignments(b mapBuilderFactory) error {
+	path, depth := p.path, p.depth
+	for {
+		p.path, p.depth, p.indexed, p.negated = path, depth, false, false
+		p.assignments++
+		// Expecting a map at the top level
+		if err := p.readMap(b); err != nil {
+			return err
+		}
+		switch tok := p.nextToken(); tok.TokenType {
+		case tokenEnd:
+			return nil
+		case tokenAssignmentSeparator:
+		default:
+			return tokenToError(tok)
+		}
+	}
+}
+
 func (p *parser) readMap(b mapBuilderFactory) error {
 	var key string
-	switch tok := p.nextToken(); tok.TokenType {
+	tok := p.nextToken()
+	if tok.TokenType == tokenNegation {
+		p.negated = true
+		tok = p.nextToken()
+	}
+	switch tok.TokenType {
 	case tokenMapKey:
 		key = tok.value
 	default:
 		return tokenToError(tok)
 	}
-	return p.readLeftValue(b.newMapBuilder(key))
+	if p.opts.topKeys != nil && p.path == "" {
+		*p.opts.topKeys = append(*p.opts.topKeys, key)
+	}
+	if err := p.descend(); err != nil {
+		return err
+	}
+	if mvb, ok := b.(builder); ok && p.opts.multiValuePaths[p.path] {
+		return p.readKeyValue(mvb, key)
+	}
+	p.path = appendKey(p.path, key)
+	p.indexed = false
+	mb := b.newMapBuilder(key)
+	if err := p.countKey(mb); err != nil {
+		return err
+	}
+	return p.readLeftValue(mb)
 }
 
 func (p *parser) readLeftValue(b builder) error {
@@ -63,64 +187,716 @@ func (p *parser) readLeftValue(b builder) error {
 		return p.readMap(b)
 	case tokenArrayIndexStart:
 		return p.readArray(b)
+	case tokenEnd, tokenAssignmentSeparator:
+		// Only bare keys end without an assignment
+		p.unreadToken(tok)
+		return p.assign(b, !p.negated)
+	case tokenDeletion:
+		if p.negated {
+			return fmt.Errorf("negated key \"%s\" cannot be deleted", p.path)
+		}
+		return p.remove(b)
 	case tokenAssignment:
+		if p.negated {
+			return fmt.Errorf("negated key \"%s\" cannot be assigned a value", p.path)
+		}
 		val, err := p.rightValueReader()
 		if err != nil {
 			return err
 		}
-		b.set(val)
+		return p.assign(b, val)
 	default:
 		return tokenToError(tok)
 	}
+}
+
+// Delete the value the builder points to, doing nothing if there is none.
+func (p *parser) remove(b builder) error {
+	d, ok := b.(deleter)
+	if !ok {
+		return fmt.Errorf("the value at path \"%s\" cannot be deleted", p.path)
+	}
+	d.delete()
+	return nil
+}
+
+// Go one level deeper in the path checking the depth limit. Existing
+// structures are only traversed along the path, one level per path element,
+// so the limit bounds the traversal of the map being merged to as well.
+func (p *parser) descend() error {
+	p.depth++
+	if p.opts.maxDepth > 0 && p.depth > p.opts.maxDepth {
+		return fmt.Errorf("path depth exceeds the maximum of %d", p.opts.maxDepth)
+	}
+	if p.opts.exactDepth > 0 && p.depth > p.opts.exactDepth {
+		return fmt.Errorf("path depth exceeds the required %d", p.opts.exactDepth)
+	}
 	return nil
 }
 
+// Count the key of the map builder if it is going to be created, checking
+// the limit of new keys.
+func (p *parser) countKey(b builder) error {
+	if p.opts.maxKeys <= 0 {
+		return nil
+	}
+	if _, ok := b.get(); !ok {
+		p.newKeys++
+	}
+	if p.newKeys > p.opts.maxKeys {
+		return fmt.Errorf("number of new map keys exceeds the maximum of %d", p.opts.maxKeys)
+	}
+	return nil
+}
+
+// Check the index, or the last index of a range, against the limit of array
+// indices. Single indices of sparse arrays are not limited, as the elements
+// before them are not allocated.
+func (p *parser) checkIndex(index, last int) error {
+	switch {
+	case last >= 0:
+		index = last
+	case p.opts.sparseArrays:
+		return nil
+	}
+	if p.opts.maxIndex <= 0 || index <= p.opts.maxIndex {
+		return nil
+	}
+	return fmt.Errorf("array index %d exceeds the maximum of %d", index, p.opts.maxIndex)
+}
+
+// Count the elements the array builder is going to fill with nil before the
+// index, checking the limit of filled gaps.
+func (p *parser) countGaps(b builder, index int) error {
+	s, ok := b.(sizer)
+	if p.opts.maxGapFill <= 0 || !ok || index <= s.size() {
+		return nil
+	}
+	p.gaps += index - s.size()
+	if p.gaps > p.opts.maxGapFill {
+		return fmt.Errorf("number of filled array gaps exceeds the maximum of %d", p.opts.maxGapFill)
+	}
+	return nil
+}
+
+// Assign the value to a leaf resolving a conflict with an existing value.
+func (p *parser) assign(b builder, val interface{}) error {
+	if p.opts.exactDepth > 0 && p.depth < p.opts.exactDepth {
+		return fmt.Errorf("path depth %d is less than the required %d", p.depth, p.opts.exactDepth)
+	}
+	if p.opts.homogeneousArrays && p.indexed {
+		if err := p.checkHomogeneous(b, val); err != nil {
+			return err
+		}
+	}
+	old, ok := b.get()
+	if ok && old != nil && p.opts.preserveTypes {
+		var err error
+		if val, err = p.preserveType(old, val); err != nil {
+			return err
+		}
+	}
+	switch {
+	case !ok || old == nil:
+	case p.opts.repeatedKeysAsList && !p.indexed:
+		list, err := appendRepeated(old, val)
+		if err != nil {
+			return err
+		}
+		val = list
+	case p.opts.conflictResolver != nil:
+		val = p.opts.conflictResolver(p.path, old, val)
+	}
+	b.set(val)
+	if t, ok := b.(trimmer); ok && val == nil && p.opts.trimTrailingNils {
+		t.trimNils()
+	}
+	if p.opts.assignedPaths != nil {
+		*p.opts.assignedPaths = append(*p.opts.assignedPaths, p.path)
+	}
+	return nil
+}
+
+// Check the value assigned to an array element has the type of the other
+// elements, ignoring null values.
+func (p *parser) checkHomogeneous(b builder, val interface{}) error {
+	eb, ok := b.(elementer)
+	if !ok || val == nil {
+		return nil
+	}
+	a, index := eb.elements()
+	for i, e := range a {
+		if i != index && e != nil && reflect.TypeOf(e) != reflect.TypeOf(val) {
+			return fmt.Errorf("value of type %T at path \"%s\" does not match the type %T of the other elements", val, p.path, e)
+		}
+	}
+	return nil
+}
+
+// Check the value has the kind of the existing one, converting it first if
+// safe conversions are enabled.
+func (p *parser) preserveType(old, val interface{}) (interface{}, error) {
+	kind := reflect.ValueOf(old).Kind()
+	if reflect.ValueOf(val).Kind() == kind {
+		return val, nil
+	}
+	if p.opts.safeConversions {
+		switch v := val.(type) {
+		case string:
+			if c := p.convert(v); reflect.ValueOf(c).Kind() == kind {
+				return c, nil
+			}
+		case int64:
+			if kind == reflect.Float64 {
+				return float64(v), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("value of type %T at path \"%s\" does not match the existing type %T", val, p.path, old)
+}
+
+// Append the value of a repeated assignment to the existing one, turning an
+// existing scalar into a list.
+func appendRepeated(old, val interface{}) (interface{}, error) {
+	switch o := old.(type) {
+	case []interface{}:
+		return append(o, val), nil
+	case map[string]interface{}, *OrderedMap:
+		return nil, errors.New("unable to append a repeated value to a map")
+	default:
+		return []interface{}{o, val}, nil
+	}
+}
+
 func (p *parser) readArray(b builder) (err error) {
 	var index int
-	switch tok := p.nextToken(); tok.TokenType {
+	var key string
+	tok := p.nextToken()
+	switch tok.TokenType {
 	case tokenArrayIndex:
 		index, err = strconv.Atoi(tok.value)
 		if err != nil {
 			return err
 		}
+	case tokenArrayKey:
+		if i, ok := p.opts.indexNames[tok.value]; ok && i >= 0 {
+			index, tok.TokenType = i, tokenArrayIndex
+			break
+		}
+		if !p.opts.durationKeys {
+			return fmt.Errorf("unknown index name \"%s\"", tok.value)
+		}
+		key, err = p.resolveBracketKey(tok.value)
+		if err != nil {
+			return err
+		}
+	case tokenArrayIndexFinish:
+		// An empty index appends to the array
+		p.unreadToken(tok)
+		index, tok.TokenType = arrayLength(b), tokenArrayIndex
 	default:
 		return tokenToError(tok)
 	}
 
-	switch tok := p.nextToken(); tok.TokenType {
+	last := -1
+	next := p.nextToken()
+	if next.TokenType == tokenArrayRangeSeparator {
+		if last, err = p.readRangeEnd(index); err != nil {
+			return err
+		}
+		next = p.nextToken()
+	} else if index < 0 {
+		// A negative index counts from the end of the array
+		l := arrayLength(b)
+		if l+index < 0 {
+			return fmt.Errorf("array index %d is out of range of the array of length %d", index, l)
+		}
+		index += l
+	}
+	switch next.TokenType {
 	case tokenArrayIndexFinish:
 	default:
+		return tokenToError(next)
+	}
+	if err := p.checkIndex(index, last); err != nil {
+		return err
+	}
+
+	if err := p.descend(); err != nil {
+		return err
+	}
+	if tok.TokenType == tokenArrayKey {
+		return p.readBracketKey(b, key)
+	}
+	if last >= 0 {
+		return p.readRange(b, index, last)
+	}
+	if p.opts.assignmentOrder != nil {
+		p.opts.assignmentOrder[p.path] = append(p.opts.assignmentOrder[p.path], index)
+	}
+	p.path = appendIndex(p.path, index)
+	p.indexed = true
+	var ab builder
+	if p.opts.sparseArrays {
+		ab = newSparseArrayBuilder(b, index)
+	} else {
+		ab = b.newArrayBuilder(index)
+	}
+	if err := p.countGaps(ab, index); err != nil {
+		return err
+	}
+	return p.readLeftValue(ab)
+}
+
+// Find the length of the array held by the builder, zero if it holds no
+// array. The length of a sparse array is its largest index plus one.
+func arrayLength(b builder) int {
+	switch v, _ := b.get(); a := v.(type) {
+	case []interface{}:
+		return len(a)
+	case SparseArray:
+		index := 0
+		for i := range a {
+			if i >= index {
+				index = i + 1
+			}
+		}
+		return index
+	}
+	return 0
+}
+
+// Read the last index of an array index range starting with the index
+// provided.
+func (p *parser) readRangeEnd(first int) (int, error) {
+	tok := p.nextToken()
+	if tok.TokenType != tokenArrayIndex {
+		return 0, tokenToError(tok)
+	}
+	last, err := strconv.Atoi(tok.value)
+	if err != nil {
+		return 0, err
+	}
+	if first < 0 {
+		return 0, fmt.Errorf("negative array index range [%d:%d]", first, last)
+	}
+	if last < first {
+		return 0, fmt.Errorf("reversed array index range [%d:%d]", first, last)
+	}
+	return last, nil
+}
+
+// Read the rest of the path after an array index range, assigning the value
+// to every index of the range.
+func (p *parser) readRange(b builder, first, last int) error {
+	indices := make([]int, 0, last-first+1)
+	for i := first; i <= last; i++ {
+		indices = append(indices, i)
+	}
+	if p.opts.assignmentOrder != nil {
+		p.opts.assignmentOrder[p.path] = append(p.opts.assignmentOrder[p.path], indices...)
+	}
+	p.path = appendRange(p.path, first, last)
+	p.indexed = true
+	if r, ok := b.(ranger); ok {
+		return p.readLeftValue(r.newRangeBuilder(first, last))
+	}
+	if err := p.countGaps(b.newArrayBuilder(first), first); err != nil {
+		return err
+	}
+	return p.readLeftValue(newRangeBuilder(b, indices))
+}
+
+// Read an assignment to the key at a multi-value path appending it to the
+// key-value pairs collected by the builder.
+func (p *parser) readKeyValue(b builder, key string) error {
+	path := p.path
+	p.path = appendKey(path, key)
+	switch tok := p.nextToken(); tok.TokenType {
+	case tokenAssignment:
+	case tokenError:
 		return tokenToError(tok)
+	default:
+		return fmt.Errorf("multi-value path \"%s\" accepts direct assignments only", path)
+	}
+	val, err := p.rightValueReader()
+	if err != nil {
+		return err
+	}
+	old, _ := b.get()
+	pairs, _ := old.([]KeyValue)
+	b.set(append(pairs, KeyValue{Key: key, Value: val}))
+	return nil
+}
+
+// Resolve the non-numeric content of square brackets to a map key.
+func (p *parser) resolveBracketKey(val string) (string, error) {
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return "", fmt.Errorf("invalid duration key \"%s\"", val)
 	}
+	return d.String(), nil
+}
 
-	return p.readLeftValue(b.newArrayBuilder(index))
+// Read the rest of the path after a map key written in square brackets.
+func (p *parser) readBracketKey(b builder, key string) error {
+	p.path = appendBracketKey(p.path, key)
+	p.indexed = false
+	mb := b.newMapBuilder(key)
+	if err := p.countKey(mb); err != nil {
+		return err
+	}
+	return p.readLeftValue(mb)
 }
 
 func (p *parser) readRightValue() (interface{}, error) {
+	var val interface{}
 	switch tok := p.nextToken(); tok.TokenType {
-	case tokenEnd:
-		return "", nil
+	case tokenEnd, tokenAssignmentSeparator:
+		p.unreadToken(tok)
+		val = ""
+	case tokenQuotedValue:
+		val = tok.value
 	case tokenValue:
-		return tryParse(tok.value), nil
+		var err error
+		if val, err = p.parseValue(p.stripComment(tok.value)); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, tokenToError(tok)
 	}
+	val = p.normalize(val)
+	if err := p.validate(val); err != nil {
+		return nil, err
+	}
+	return val, nil
 }
 
 func (p *parser) readRightString() (interface{}, error) {
+	var val interface{}
 	switch tok := p.nextToken(); tok.TokenType {
-	case tokenEnd:
-		return "", nil
+	case tokenEnd, tokenAssignmentSeparator:
+		p.unreadToken(tok)
+		val = ""
+	case tokenQuotedValue:
+		val = tok.value
 	case tokenValue:
-		return tok.value, nil
+		var err error
+		if val, err = p.parseString(p.stripComment(tok.value)); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, tokenToError(tok)
 	}
+	return p.normalize(val), nil
 }
 
-func tryParse(val string) interface{} {
-	b, err := strconv.ParseBool(val)
-	if err == nil {
+// Parse the value keeping it a string.
+func (p *parser) parseString(str string) (interface{}, error) {
+	if isBraceList(str) {
+		return toValues(splitBraceList(str[1 : len(str)-1])), nil
+	}
+	if p.opts.goUnquote && isGoQuoted(str) {
+		return goUnquote(str)
+	}
+	if p.opts.stripQuotes && isStrippable(str) {
+		return str[1 : len(str)-1], nil
+	}
+	str, err := p.preprocess(str)
+	if err != nil || p.opts.valueSeparator == 0 {
+		return str, err
+	}
+	if parts, ok := splitValue(str, p.opts.valueSeparator); ok {
+		return toValues(parts), nil
+	}
+	return unescapeSeparator(str, p.opts.valueSeparator), nil
+}
+
+// Normalize the value if it is a string or normalize its elements if it is
+// an array.
+func (p *parser) normalize(val interface{}) interface{} {
+	switch v := val.(type) {
+	case string:
+		return p.normalizeString(v)
+	case []interface{}:
+		for i, e := range v {
+			if s, ok := e.(string); ok {
+				v[i] = p.normalizeString(s)
+			}
+		}
+	}
+	return val
+}
+
+func (p *parser) normalizeString(str string) string {
+	if p.opts.foldLines || p.opts.stripIndent {
+		str = foldBlock(str, p.opts.foldLines, p.opts.stripIndent)
+	}
+	if p.opts.stringNormalizer != nil {
+		str = p.opts.stringNormalizer(str)
+	}
+	return str
+}
+
+// Strip the comment following the value recording it under the path.
+func (p *parser) stripComment(str string) string {
+	if p.opts.comments == nil {
+		return str
+	}
+	val, comment, ok := splitComment(str)
+	if ok {
+		p.opts.comments[p.path] = comment
+	}
+	return val
+}
+
+// Parse the value converting it to the most suitable type.
+func (p *parser) parseValue(str string) (interface{}, error) {
+	switch {
+	case p.opts.emptySentinel != nil && str == *p.opts.emptySentinel:
+		return "", nil
+	case p.opts.nullSentinel != nil && str == *p.opts.nullSentinel:
+		return nil, nil
+	}
+	if p.opts.anchors != nil {
+		if name, rest, ok := parseAnchor(str); ok {
+			val, err := p.parseValue(rest)
+			if err != nil {
+				return nil, err
+			}
+			p.opts.anchors[name] = copyValue(val)
+			return val, nil
+		}
+		if name, ok := parseAlias(str); ok {
+			val, ok := p.opts.anchors[name]
+			if !ok {
+				return nil, fmt.Errorf("undefined alias \"%s\"", name)
+			}
+			return copyValue(val), nil
+		}
+	}
+	if p.opts.shellSplitPaths[p.path] {
+		return shellSplit(str)
+	}
+	if p.opts.nestedValues && strings.HasPrefix(str, nestedPrefix) {
+		return p.parseNested(str[len(nestedPrefix):])
+	}
+	if p.opts.orderedValues && isBraced(str) {
+		return p.parseOrdered(str[1 : len(str)-1])
+	}
+	if isBraceList(str) {
+		return p.convertAll(splitBraceList(str[1 : len(str)-1])), nil
+	}
+	if p.opts.goUnquote && isGoQuoted(str) {
+		return goUnquote(str)
+	}
+	if p.opts.stripQuotes && isStrippable(str) {
+		return str[1 : len(str)-1], nil
+	}
+	str, err := p.preprocess(str)
+	if err != nil {
+		return nil, err
+	}
+	if p.opts.references {
+		if ref, ok := parseReference(str); ok {
+			return ref, nil
+		}
+	}
+	if p.opts.matrixValues && strings.HasPrefix(str, "[") {
+		return parseMatrix(str, p.convert)
+	}
+	if p.opts.valueSeparator != 0 {
+		if parts, ok := splitValue(str, p.opts.valueSeparator); ok {
+			return p.convertAll(parts), nil
+		}
+		str = unescapeSeparator(str, p.opts.valueSeparator)
+	}
+	if p.opts.valueSigils {
+		if val, ok, err := parseSigil(p.path, str); ok {
+			return val, err
+		}
+		str = unescapeSigil(str)
+	}
+	if p.opts.regexpValues {
+		if strings.HasPrefix(str, regexpPrefix) {
+			return compileRegexp(str)
+		}
+		if strings.HasPrefix(str, escapedRegexpPrefix) {
+			str = str[1:]
+		}
+	}
+	if prefix, parse, ok := matchUnionPrefix(p.opts.unionParsers, str); ok {
+		return parse(str[len(prefix):])
+	}
+	if p.opts.globPaths[p.path] {
+		return globValue(p.opts.globFS, str)
+	}
+	if p.opts.filePaths[p.path] {
+		return resolveFilePath(p.opts.basePath, str), nil
+	}
+	if p.opts.versionPaths[p.path] {
+		return ParseConstraints(str)
+	}
+	if p.opts.unixTimePaths[p.path] {
+		if t, ok := parseUnixTime(str, p.opts.unixTimeUnit); ok {
+			return t, nil
+		}
+		if _, ok := p.opts.timeLayouts[p.path]; !ok {
+			return nil, fmt.Errorf("value \"%s\" at path \"%s\" is not a Unix time", str, p.path)
+		}
+	}
+	if layout, ok := p.opts.timeLayouts[p.path]; ok {
+		return parseTime(p.path, str, layout)
+	}
+	if t, ok := p.opts.schema[p.path]; ok {
+		if t == TypeBool && p.opts.tristateBools {
+			return coerceTristate(p.path, str, p.opts.numericBools)
+		}
+		return coerce(p.path, str, t, p.opts.numericBools)
+	}
+	val := p.convert(str)
+	if p.opts.coercionWarnings != nil && isLossy(str, val) {
+		*p.opts.coercionWarnings = append(*p.opts.coercionWarnings, CoercionWarning{
+			Path:    p.path,
+			Literal: str,
+			Value:   val,
+		})
+	}
+	return val, nil
+}
+
+// Parse the value as a nested expression into a map. The nested parser
+// continues the path and the limits of the current one.
+func (p *parser) parseNested(str string) (interface{}, error) {
+	m := map[string]interface{}{}
+	if err := p.mergeNested(newRootBuilder(m), str); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Parse the fields of a braced value, separated by semicolons, as nested
+// expressions into an ordered map keeping the order of the fields.
+func (p *parser) parseOrdered(str string) (interface{}, error) {
+	m := NewOrderedMap()
+	for _, field := range splitFields(str) {
+		if field == "" {
+			continue
+		}
+		if err := p.mergeNested(newOrderedRootBuilder(m), field); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Merge the nested expression with a parser continuing the path and the
+// limits of the current one.
+func (p *parser) mergeNested(b mapBuilderFactory, str string) error {
+	nested := &parser{
+		lex:     newLex(str, p.opts),
+		opts:    p.opts,
+		path:    p.path,
+		depth:   p.depth,
+		newKeys: p.newKeys,
+		gaps:    p.gaps,
+	}
+	nested.rightValueReader = nested.readRightValue
+	if err := nested.merge(b, str); err != nil {
+		return err
+	}
+	p.newKeys, p.gaps = nested.newKeys, nested.gaps
+	return nil
+}
+
+// Apply the enabled transformations to a value before it gets converted.
+func (p *parser) preprocess(val string) (string, error) {
+	if p.opts.rejectSpaces && !isQuoted(val) && containsSpace(val) {
+		return "", fmt.Errorf("unquoted value \"%s\" contains spaces", val)
+	}
+	if p.opts.templates {
+		return renderTemplate(val, p.opts.templateData)
+	}
+	return val, nil
+}
+
+// Convert the value to the most suitable type.
+func (p *parser) convert(val string) interface{} {
+	literals := p.opts.literals
+	if literals == nil {
+		literals = &defaultLiterals
+	}
+	if p.opts.boolDetector != nil {
+		if b, ok := p.opts.boolDetector(val); ok {
+			return b
+		}
+	}
+	var res interface{}
+	if p.opts.converters != nil {
+		res = tryConverters(val, p.opts.converters)
+	} else {
+		res = tryParse(val, literals)
+	}
+	if _, ok := res.(string); !ok {
+		return res
+	}
+	if p.opts.ipParsing {
+		if ip, ok := parseIP(val); ok {
+			return ip
+		}
+	}
+	if p.opts.complexParsing {
+		if c, err := strconv.ParseComplex(val, 128); err == nil {
+			return c
+		}
+	}
+	if p.opts.quantityParsing {
+		if q, ok := parseQuantity(val); ok {
+			return q
+		}
+	}
+	if p.opts.byteSizeParsing && (p.opts.byteSizePaths == nil || p.opts.byteSizePaths[p.path]) {
+		if n, ok := parseByteSize(val, p.opts.byteSizeBinary); ok {
+			return n
+		}
+	}
+	if p.opts.unitSplitting {
+		if q, ok := parseUnit(val); ok {
+			return q
+		}
+	}
+	if p.opts.uuidParsing {
+		if u, ok := parseUUID(val); ok {
+			return u
+		}
+	}
+	for _, pp := range p.opts.patternParsers {
+		if match := pp.Pattern.FindStringSubmatch(val); match != nil {
+			return pp.Convert(match)
+		}
+	}
+	return res
+}
+
+// Convert each of the values to the most suitable type.
+func (p *parser) convertAll(vals []string) []interface{} {
+	a := make([]interface{}, len(vals))
+	for i, val := range vals {
+		a[i] = p.convert(val)
+	}
+	return a
+}
+
+// Validate the converted value against the constraints of its path.
+func (p *parser) validate(val interface{}) error {
+	if bounds, ok := p.opts.numericRanges[p.path]; ok {
+		return checkRange(p.path, val, bounds)
+	}
+	return nil
+}
+
+func tryParse(val string, literals *LiteralsTable) interface{} {
+	if b, ok := literals.parseBool(val); ok {
 		return b
 	}
 	i, err := strconv.ParseInt(val, 10, 64)
@@ -131,12 +907,21 @@ func tryParse(val string) interface{} {
 	if err == nil {
 		return f
 	}
-	if val == "null" || val == "NULL" || val == "Null" {
+	if literals.isNull(val) {
 		return nil
 	}
 	return val
 }
 
+func tryConverters(val string, converters []Converter) interface{} {
+	for _, c := range converters {
+		if res, ok := c(val); ok {
+			return res
+		}
+	}
+	return val
+}
+
 func tokenToError(tok token) error {
 	if tok.TokenType == tokenError {
 		return errors.New(tok.value)
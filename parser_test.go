@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 type parserTestCase struct {
@@ -94,6 +95,12 @@ func Test_Value_Parser_Succeeds(t *testing.T) {
 				"key": int64(1000),
 			},
 		),
+		newParserTestCase(
+			"a boolean value 1", "key=1",
+			map[string]interface{}{
+				"key": true,
+			},
+		),
 		newParserTestCase(
 			"a floating point value 10.01", "key=10.01",
 			map[string]interface{}{
@@ -156,6 +163,91 @@ func Test_String_Parser_Succeeds(t *testing.T) {
 	}
 }
 
+func Test_Parse_Returns_New_Maps(t *testing.T) {
+	test := newParserTestCase(
+		"parsing values", "foo.bar=5,baz[1]=true",
+		map[string]interface{}{
+			"foo": map[string]interface{}{
+				"bar": int64(5),
+			},
+			"baz": []interface{}{nil, true},
+		},
+	)
+	m, err := Parse(test.input)
+	assertNoError(t, err, test, m)
+
+	test = newParserTestCase(
+		"parsing strings", "foo.bar=1,baz[1]=true",
+		map[string]interface{}{
+			"foo": map[string]interface{}{
+				"bar": "1",
+			},
+			"baz": []interface{}{nil, "true"},
+		},
+	)
+	m, err = ParseString(test.input)
+	assertNoError(t, err, test, m)
+
+	errorTest := newParserErrorTestCase(
+		"a partially valid input", "foo=1,bar[",
+		"unable to parse \"foo=1,bar[\", unexpected end, expecting an array index",
+	)
+	for _, parse := range []func(string, ...Option) (map[string]interface{}, error){Parse, ParseString} {
+		m, err := parse(errorTest.input)
+		assertError(t, err, errorTest)
+		if m != nil {
+			t.Errorf("In the case of %s expected a nil map, got %v", errorTest.desc, m)
+		}
+	}
+}
+
+func Test_ParseScalar_Converts_Values(t *testing.T) {
+	date := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		desc     string
+		input    string
+		opts     []Option
+		expected interface{}
+	}{
+		{"a bool", "true", nil, true},
+		{"an int", "42", nil, int64(42)},
+		{"a float", "4.2", nil, 4.2},
+		{"a null", "null", nil, nil},
+		{"a string with a comma", "a,b", nil, "a,b"},
+		{"a quoted value", "\"42\"", nil, "42"},
+		{"an escape", "a\\nb", nil, "a\nb"},
+		{"an empty value", "", nil, ""},
+		{"a date", "2023-01-01", []Option{WithTimeLayouts(map[string]string{"": "2006-01-02"})}, date},
+		{"an undeclared date", "2023-01-01", nil, "2023-01-01"},
+	}
+	for _, test := range testCases {
+		val, err := ParseScalar(test.input, test.opts...)
+		if err != nil {
+			t.Errorf("In the case of %s \"%s\" expected success, got %v", test.desc, test.input, err)
+		} else if !reflect.DeepEqual(val, test.expected) {
+			t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\t%#v\ngot:\n\t%#v",
+				test.desc, test.input, test.expected, val)
+		}
+	}
+
+	errorTest := newParserErrorTestCase(
+		"an invalid date", "2023-13-01",
+		"unable to parse \"2023-13-01\", value \"2023-13-01\" at path \"\" does not match the time layout \"2006-01-02\"",
+	)
+	val, err := ParseScalar(errorTest.input, WithTimeLayouts(map[string]string{"": "2006-01-02"}))
+	assertError(t, err, errorTest)
+	if val != nil {
+		t.Errorf("In the case of %s expected a nil value, got %v", errorTest.desc, val)
+	}
+
+	errorTest = newParserErrorTestCase(
+		"characters after the closing quote", "\"a\"b",
+		"unable to parse \"\"a\"b\", in position 4 got unexpected character: U+0062 'b', expecting ',' after the closing quote",
+	)
+	_, err = ParseScalar(errorTest.input)
+	assertError(t, err, errorTest)
+}
+
 func assertNoError(t *testing.T, err error, test parserTestCase, m map[string]interface{}) {
 	if err != nil {
 		t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\tsuccess\ngot:\n\t%+v",
@@ -168,6 +260,18 @@ func assertNoError(t *testing.T, err error, test parserTestCase, m map[string]in
 	}
 }
 
+func assertError(t *testing.T, err error, test parserErrorTestCase) {
+	if err == nil {
+		t.Errorf("\nIn the case of %s \"%s\"\nexpected error:\n\t%+v\ngot:\n\tsuccess",
+			test.desc, test.input, test.expected)
+	} else {
+		if err.Error() != test.expected {
+			t.Errorf("\nIn the case of %s \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v",
+				test.desc, test.input, test.expected, err)
+		}
+	}
+}
+
 func Test_Parser_Accumulates_In_Input_Map(t *testing.T) {
 	testCases := []parserTestCase{
 		newParserTestCase(
@@ -250,6 +354,37 @@ func Test_Parser_Accumulates_In_Input_Map(t *testing.T) {
 				},
 			},
 		),
+		newParserTestCase(
+			"deep-merging a map in an array", "foo[0].bar.key1=val1,foo[0].bar.key2=val2",
+			map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{
+						"bar": map[string]interface{}{
+							"key1": "val1",
+							"key2": "val2",
+						},
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"a scalar in an array replaced by a map", "foo[0]=val,foo[0].key=val",
+			map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{
+						"key": "val",
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"a map in an array replaced by a scalar", "foo[0].key=val,foo[0]=val",
+			map[string]interface{}{
+				"foo": []interface{}{
+					"val",
+				},
+			},
+		),
 		newParserTestCase(
 			"a value overridden by a null value", "foo[0]=val,foo[0]=null",
 			map[string]interface{}{
@@ -275,6 +410,530 @@ func Test_Parser_Accumulates_In_Input_Map(t *testing.T) {
 				test.desc, test.input, test.expected, m)
 		}
 	}
+
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input)
+		assertNoError(t, err, test, m)
+	}
+}
+
+func Test_Parser_Merges_Multiple_Assignments(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"an escaped comma in a value", "a=x\\,y,b=z",
+			map[string]interface{}{
+				"a": "x,y",
+				"b": "z",
+			},
+		),
+		newParserTestCase(
+			"an escaped comma in a key", "a\\,b=x,c=y",
+			map[string]interface{}{
+				"a,b": "x",
+				"c":   "y",
+			},
+		),
+		newParserTestCase(
+			"a comma in double quotes", "a=\"x,y\",b=z",
+			map[string]interface{}{
+				"a": "x,y",
+				"b": "z",
+			},
+		),
+		newParserTestCase(
+			"an empty value", "a=,b=5",
+			map[string]interface{}{
+				"a": "",
+				"b": int64(5),
+			},
+		),
+		newParserTestCase(
+			"a single assignment", "a=x",
+			map[string]interface{}{
+				"a": "x",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input)
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an invalid second assignment", "a=x,b[x]=y",
+			"unable to parse \"a=x,b[x]=y\", in position 7 got unexpected character: U+0078 'x', expecting an array index",
+		),
+		newParserErrorTestCase(
+			"a trailing comma", "a=x,",
+			"unable to parse \"a=x,\", unexpected end, expecting a map key",
+		),
+		newParserErrorTestCase(
+			"a comma in a key", "a,b=x",
+			"unable to parse \"a,b=x\", in position 2 got unexpected character: U+002C ',', expecting '.', '=' or '['",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input)
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Keeps_Assignments_Before_A_Failure(t *testing.T) {
+	input := "a=5,b.=2"
+	m := map[string]interface{}{}
+	if err := MergeValue(m, input); err == nil {
+		t.Fatalf("Expected an error for \"%s\", got success", input)
+	}
+	expected := map[string]interface{}{
+		"a": int64(5),
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("\nIn the case of \"%s\"\nexpected:\n\t%+v\ngot:\n\t%+v", input, expected, m)
+	}
+
+	res, err := Parse(input)
+	if err == nil || res != nil {
+		t.Errorf("\nIn the case of \"%s\"\nexpected a nil map and an error, got %+v and %v", input, res, err)
+	}
+}
+
+func Test_Parser_Parses_Brace_Lists(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		input string
+		value interface{}
+		str   interface{}
+	}{
+		{
+			"a list", "key={a,5,true}",
+			[]interface{}{"a", int64(5), true},
+			[]interface{}{"a", "5", "true"},
+		},
+		{
+			"an empty list", "key={}",
+			[]interface{}{},
+			[]interface{}{},
+		},
+		{
+			"escaped commas and braces", "key={a\\,b,c\\},d\\x}",
+			[]interface{}{"a,b", "c}", "d\\x"},
+			[]interface{}{"a,b", "c}", "d\\x"},
+		},
+		{
+			"an escaped closing brace", "key={a\\}",
+			"{a\\}",
+			"{a\\}",
+		},
+	}
+	for _, test := range testCases {
+		for _, merge := range []struct {
+			f        func(map[string]interface{}, string, ...Option) error
+			expected interface{}
+		}{
+			{MergeValue, test.value},
+			{MergeString, test.str},
+		} {
+			m := map[string]interface{}{}
+			err := merge.f(m, test.input)
+			assertNoError(t, err, newParserTestCase(test.desc, test.input, map[string]interface{}{
+				"key": merge.expected,
+			}), m)
+		}
+	}
+
+	test := newParserTestCase(
+		"a list followed by an assignment", "a={x,y},b=z",
+		map[string]interface{}{
+			"a": []interface{}{"x", "y"},
+			"b": "z",
+		},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input), test, m)
+}
+
+func Test_Parser_Keeps_Quoted_Values(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a quoted number", "key=\"1000\"",
+			map[string]interface{}{
+				"key": "1000",
+			},
+		),
+		newParserTestCase(
+			"a quoted boolean", "key=\"true\"",
+			map[string]interface{}{
+				"key": "true",
+			},
+		),
+		newParserTestCase(
+			"an empty quoted value", "key=\"\"",
+			map[string]interface{}{
+				"key": "",
+			},
+		),
+		newParserTestCase(
+			"escapes in quotes", "key=\"a\\\"b\\\\c\\nd\\=\"",
+			map[string]interface{}{
+				"key": "a\"b\\c\nd=",
+			},
+		),
+		newParserTestCase(
+			"a quoted value followed by an assignment", "a=\"1,2\",b=c",
+			map[string]interface{}{
+				"a": "1,2",
+				"b": "c",
+			},
+		),
+		newParserTestCase(
+			"a quote inside a value", "key=a\"1\"",
+			map[string]interface{}{
+				"key": "a\"1\"",
+			},
+		),
+		newParserTestCase(
+			"escaped quotes around a value", "key=\\\"x\\\"",
+			map[string]interface{}{
+				"key": "\"x\"",
+			},
+		),
+	}
+	for _, test := range testCases {
+		for _, merge := range []func(map[string]interface{}, string, ...Option) error{MergeValue, MergeString} {
+			m := map[string]interface{}{}
+			err := merge(m, test.input)
+			assertNoError(t, err, test, m)
+		}
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unterminated quote", "key=\"",
+			"unable to parse \"key=\"\", unterminated quote in position 5",
+		),
+		newParserErrorTestCase(
+			"an unterminated quote with an escape", "key=\"a\\\"",
+			"unable to parse \"key=\"a\\\"\", unterminated quote in position 5",
+		),
+		newParserErrorTestCase(
+			"trailing characters", "key=\"a\"b",
+			"unable to parse \"key=\"a\"b\", in position 8 got unexpected character: U+0062 'b', expecting ',' after the closing quote",
+		),
+	}
+	for _, test := range errorTestCases {
+		assertError(t, MergeValue(map[string]interface{}{}, test.input), test)
+	}
+}
+
+func Test_Parser_Interprets_Value_Escapes(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a new line and a tab", "key=a\\nb\\tc",
+			map[string]interface{}{
+				"key": "a\nb\tc",
+			},
+		),
+		newParserTestCase(
+			"an escaped backslash", "key=C:\\\\dir",
+			map[string]interface{}{
+				"key": "C:\\dir",
+			},
+		),
+		newParserTestCase(
+			"an escaped assignment operator", "key=\\=",
+			map[string]interface{}{
+				"key": "=",
+			},
+		),
+		newParserTestCase(
+			"escaped path characters and quotes", "key=a\\.b\\[0\\]\\\"",
+			map[string]interface{}{
+				"key": "a.b[0]\"",
+			},
+		),
+		newParserTestCase(
+			"unicode escapes", "caf\\u00e9=\"\\u00e9\\ud83d\\ude00\"",
+			map[string]interface{}{
+				"caf\u00e9": "\u00e9\U0001f600",
+			},
+		),
+		newParserTestCase(
+			"escapes in a brace list", "key={a\\,b,c}",
+			map[string]interface{}{
+				"key": []interface{}{"a,b", "c"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input)
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an unknown escape", "key=C:\\dir",
+			"unable to parse \"key=C:\\dir\", in position 8 got unknown escape sequence: character: U+0064 'd'",
+		),
+		newParserErrorTestCase(
+			"an unknown letter escape", "key=\\x",
+			"unable to parse \"key=\\x\", in position 6 got unknown escape sequence: character: U+0078 'x'",
+		),
+		newParserErrorTestCase(
+			"an unknown punctuation escape", "key=a\\;b",
+			"unable to parse \"key=a\\;b\", in position 7 got unknown escape sequence: character: U+003B ';'",
+		),
+		newParserErrorTestCase(
+			"a trailing backslash", "key=a\\",
+			"unable to parse \"key=a\\\", incomplete escape sequence: end",
+		),
+	}
+	for _, test := range errorTestCases {
+		assertError(t, MergeValue(map[string]interface{}{}, test.input), test)
+	}
+}
+
+func Test_Parser_Appends_With_Empty_Indices(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"appending to a missing array", "foo[]=a",
+			map[string]interface{}{
+				"foo": []interface{}{"a"},
+			},
+		),
+		newParserTestCase(
+			"appending in sequence", "foo[]=a,foo[]=b",
+			map[string]interface{}{
+				"foo": []interface{}{"a", "b"},
+			},
+		),
+		newParserTestCase(
+			"appending after an index", "foo[1]=a,foo[]=b",
+			map[string]interface{}{
+				"foo": []interface{}{nil, "a", "b"},
+			},
+		),
+		newParserTestCase(
+			"appending maps", "foo[].name=a,foo[].name=b",
+			map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{"name": "a"},
+					map[string]interface{}{"name": "b"},
+				},
+			},
+		),
+		newParserTestCase(
+			"appending to a nested array", "foo[0][]=a,foo[0][]=b",
+			map[string]interface{}{
+				"foo": []interface{}{
+					[]interface{}{"a", "b"},
+				},
+			},
+		),
+		newParserTestCase(
+			"a scalar overwritten", "foo=a,foo[]=b",
+			map[string]interface{}{
+				"foo": []interface{}{"b"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input)
+		assertNoError(t, err, test, m)
+	}
+
+	test := newParserTestCase(
+		"appending to a sparse array", "foo[5]=a,foo[]=b",
+		map[string]interface{}{
+			"foo": SparseArray{5: "a", 6: "b"},
+		},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeValue(m, test.input, WithSparseArrays()), test, m)
+}
+
+func Test_Parser_Deletes_Values(t *testing.T) {
+	newMap := func() map[string]interface{} {
+		return map[string]interface{}{
+			"name": "app",
+			"db": map[string]interface{}{
+				"host": "db1",
+				"port": int64(5432),
+			},
+			"tags": []interface{}{"a", "b", "c", "d"},
+		}
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a root key", "name-",
+			map[string]interface{}{
+				"db": map[string]interface{}{
+					"host": "db1",
+					"port": int64(5432),
+				},
+				"tags": []interface{}{"a", "b", "c", "d"},
+			},
+		),
+		newParserTestCase(
+			"a nested key followed by an assignment", "db.port-,db.user=x",
+			map[string]interface{}{
+				"name": "app",
+				"db": map[string]interface{}{
+					"host": "db1",
+					"user": "x",
+				},
+				"tags": []interface{}{"a", "b", "c", "d"},
+			},
+		),
+		newParserTestCase(
+			"an array element", "tags[1]-",
+			map[string]interface{}{
+				"name": "app",
+				"db": map[string]interface{}{
+					"host": "db1",
+					"port": int64(5432),
+				},
+				"tags": []interface{}{"a", "c", "d"},
+			},
+		),
+		newParserTestCase(
+			"a range of array elements", "tags[1:2]-",
+			map[string]interface{}{
+				"name": "app",
+				"db": map[string]interface{}{
+					"host": "db1",
+					"port": int64(5432),
+				},
+				"tags": []interface{}{"a", "d"},
+			},
+		),
+		newParserTestCase(
+			"missing keys and elements", "missing-,db.missing.key-,tags[9]-,name.key-",
+			newMap(),
+		),
+		newParserTestCase(
+			"a key with a dash", "db.host-name=x,db.host-name-",
+			newMap(),
+		),
+		newParserTestCase(
+			"a key ending with a dash assigned", "name-=x,name-",
+			map[string]interface{}{
+				"name-": "x",
+				"db": map[string]interface{}{
+					"host": "db1",
+					"port": int64(5432),
+				},
+				"tags": []interface{}{"a", "b", "c", "d"},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := newMap()
+		err := MergeValue(m, test.input, WithIndexRanges())
+		assertNoError(t, err, test, m)
+	}
+
+	test := newParserTestCase(
+		"a sparse array element", "tags[2]=c,tags[8]=x,tags[8]-",
+		map[string]interface{}{
+			"tags": []interface{}{"a", "b", "c"},
+		},
+	)
+	m := map[string]interface{}{"tags": []interface{}{"a", "b"}}
+	assertNoError(t, MergeValue(m, test.input, WithSparseArrays()), test, m)
+
+	errorTest := newParserErrorTestCase(
+		"a negated deletion", "!name-",
+		"unable to parse \"!name-\", negated key \"name\" cannot be deleted",
+	)
+	assertError(t, MergeValue(newMap(), errorTest.input, WithBareKeyBooleans()), errorTest)
+}
+
+func Test_Parser_Resolves_Negative_Indices(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"the last element", "foo[2]=c,foo[-1]=last",
+			map[string]interface{}{
+				"foo": []interface{}{nil, nil, "last"},
+			},
+		),
+		newParserTestCase(
+			"the first element of a longer array", "foo[]=a,foo[]=b,foo[-2]=first",
+			map[string]interface{}{
+				"foo": []interface{}{"first", "b"},
+			},
+		),
+		newParserTestCase(
+			"a key under the last element", "foo[0].a=5,foo[-1].b=2",
+			map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{"a": int64(5), "b": int64(2)},
+				},
+			},
+		),
+		newParserTestCase(
+			"a nested array", "foo[0][1]=a,foo[-1][-1]=b",
+			map[string]interface{}{
+				"foo": []interface{}{
+					[]interface{}{nil, "b"},
+				},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input)
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an index before the start", "foo[0]=a,foo[-2]=b",
+			"unable to parse \"foo[0]=a,foo[-2]=b\", array index -2 is out of range of the array of length 1",
+		),
+		newParserErrorTestCase(
+			"an index of a missing array", "foo[-1]=a",
+			"unable to parse \"foo[-1]=a\", array index -1 is out of range of the array of length 0",
+		),
+		newParserErrorTestCase(
+			"a sign without digits", "foo[-]=a",
+			"unable to parse \"foo[-]=a\", in position 6 got unexpected character: U+005D ']', expecting an array index",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input)
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Writes_Caller_Slices_In_Place(t *testing.T) {
+	s := []interface{}{"a", "b"}
+	m := map[string]interface{}{
+		"foo": s,
+	}
+	if err := MergeValue(m, "foo[1]=x"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if s[1] != "x" {
+		t.Errorf("Expected the caller's slice to be written in place, got %v", s)
+	}
+
+	if err := MergeValue(m, "foo[3]=y"); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	expected := []interface{}{"a", "x", nil, "y"}
+	if !reflect.DeepEqual(m["foo"], expected) {
+		t.Errorf("Expected the map to hold the grown array %v, got %v", expected, m["foo"])
+	}
+	if len(s) != 2 {
+		t.Errorf("Expected the caller's slice to keep its length, got %v", s)
+	}
 }
 
 type parserErrorTestCase struct {
@@ -313,6 +972,10 @@ func Test_Parser_Fails(t *testing.T) {
 			"an array index is not complete", "foo[0",
 			"unable to parse \"foo[0\", unexpected end, expecting ']'",
 		),
+		newParserErrorTestCase(
+			"a key with a trailing backslash", "foo\\",
+			"unable to parse \"foo\\\", incomplete escape sequence: end",
+		),
 		newParserErrorTestCase(
 			"an array index is out of range", "foo[99999999999999999999]",
 			"unable to parse \"foo[99999999999999999999]\", strconv.Atoi: parsing \"99999999999999999999\": value out of range",
@@ -360,7 +1023,7 @@ type fakeLexer struct {
 	tokens []token
 }
 
-func (l *fakeLexer) drain() {
+func (l *fakeLexer) close() {
 }
 
 func (l *fakeLexer) nextToken() token {
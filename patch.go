@@ -0,0 +1,71 @@
+package djson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ApplyMergePatch applies the JSON Merge Patch document provided, as defined
+// by RFC 7386, to the map: null values in the patch delete keys, objects are
+// merged into maps recursively and any other value, including an array,
+// replaces the value under its key. Numbers are stored as int64 when they are
+// integers and as float64 otherwise, the same way MergeValue converts them.
+// The patch has to be a JSON object.
+func ApplyMergePatch(m map[string]interface{}, patch []byte) error {
+	d := json.NewDecoder(bytes.NewReader(patch))
+	d.UseNumber()
+	var p interface{}
+	if err := d.Decode(&p); err != nil {
+		return fmt.Errorf("unable to decode the patch, %v", err)
+	}
+	if d.More() {
+		return errors.New("unable to decode the patch, unexpected data after the document")
+	}
+	obj, ok := p.(map[string]interface{})
+	if !ok {
+		return errors.New("unable to apply the patch, expecting a JSON object")
+	}
+	mergePatch(m, obj)
+	return nil
+}
+
+func mergePatch(m, patch map[string]interface{}) {
+	for key, val := range patch {
+		switch v := val.(type) {
+		case nil:
+			delete(m, key)
+		case map[string]interface{}:
+			target, ok := m[key].(map[string]interface{})
+			if !ok {
+				target = map[string]interface{}{}
+			}
+			mergePatch(target, v)
+			m[key] = target
+		default:
+			m[key] = patchValue(v)
+		}
+	}
+}
+
+// Convert the decoded JSON value to the types produced by the parsing.
+func patchValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for key, e := range v {
+			v[key] = patchValue(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = patchValue(e)
+		}
+	}
+	return val
+}
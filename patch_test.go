@@ -0,0 +1,61 @@
+package djson
+
+import (
+	"testing"
+)
+
+func Test_ApplyMergePatch_Succeeds(t *testing.T) {
+	patch := `{
+		"name": "web",
+		"port": 8080,
+		"ratio": 0.5,
+		"legacy": null,
+		"db": {"user": null, "pool": {"size": 10}},
+		"tags": ["b", {"x": 1}],
+		"missing": null,
+		"scalar": {"a": true}
+	}`
+	test := newParserTestCase(
+		"adding, changing and deleting keys", patch,
+		map[string]interface{}{
+			"name":  "web",
+			"port":  int64(8080),
+			"ratio": 0.5,
+			"db": map[string]interface{}{
+				"host": "db1",
+				"pool": map[string]interface{}{
+					"size": int64(10),
+				},
+			},
+			"tags": []interface{}{
+				"b",
+				map[string]interface{}{"x": int64(1)},
+			},
+			"scalar": map[string]interface{}{"a": true},
+		},
+	)
+	m := mergeAll(t, "name=app,port=80,legacy.on=true,db.host=db1,db.user=admin,tags[0]=a,tags[1]=c,scalar=1")
+	assertNoError(t, ApplyMergePatch(m, []byte(test.input)), test, m)
+}
+
+func Test_ApplyMergePatch_Fails(t *testing.T) {
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a patch which is not an object", `["a"]`,
+			"unable to apply the patch, expecting a JSON object",
+		),
+		newParserErrorTestCase(
+			"invalid JSON", `{"a":`,
+			"unable to decode the patch, unexpected EOF",
+		),
+		newParserErrorTestCase(
+			"trailing data", `{"a": 1} {}`,
+			"unable to decode the patch, unexpected data after the document",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := ApplyMergePatch(m, []byte(test.input))
+		assertError(t, err, test)
+	}
+}
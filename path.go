@@ -0,0 +1,129 @@
+package djson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Syntax declares the characters of the path syntax, letting keys contain
+// the default ones without escaping them. The zero fields stand for the
+// default characters.
+type Syntax struct {
+	KeySeparator rune // Separates map keys, '.' by default
+	Assignment   rune // Assigns values to paths, '=' by default
+	IndexStart   rune // Starts array indices, '[' by default
+	IndexFinish  rune // Finishes array indices, ']' by default
+}
+
+var defaultSyntax = Syntax{
+	KeySeparator: '.',
+	Assignment:   '=',
+	IndexStart:   '[',
+	IndexFinish:  ']',
+}
+
+// Fill the zero fields of the syntax with the default characters.
+func (s Syntax) withDefaults() Syntax {
+	if s.KeySeparator == 0 {
+		s.KeySeparator = defaultSyntax.KeySeparator
+	}
+	if s.Assignment == 0 {
+		s.Assignment = defaultSyntax.Assignment
+	}
+	if s.IndexStart == 0 {
+		s.IndexStart = defaultSyntax.IndexStart
+	}
+	if s.IndexFinish == 0 {
+		s.IndexFinish = defaultSyntax.IndexFinish
+	}
+	return s
+}
+
+// Check that the characters of the syntax are distinct and do not have
+// other special meanings.
+func (s Syntax) check() error {
+	chars := []rune{s.KeySeparator, s.Assignment, s.IndexStart, s.IndexFinish}
+	for i, r := range chars {
+		if r == ',' || r == '\\' || r == '-' || r == ':' {
+			return fmt.Errorf("invalid syntax character '%c'", r)
+		}
+		for _, other := range chars[:i] {
+			if r == other {
+				return fmt.Errorf("repeated syntax character '%c'", r)
+			}
+		}
+	}
+	return nil
+}
+
+// The characters ending a map key in the syntax.
+func (s Syntax) stopChars() map[strRune]bool {
+	if s == defaultSyntax {
+		return stopLeftValueChars
+	}
+	return map[strRune]bool{
+		strRune(s.Assignment):   true,
+		strRune(s.KeySeparator): true,
+		strRune(s.IndexStart):   true,
+		',':                     true,
+	}
+}
+
+// Escape the characters having special meaning in a map key.
+func escapeKey(key string) string {
+	return escapeKeyChars(key, stopLeftValueChars)
+}
+
+// Escape the backslashes and the characters ending a map key.
+func escapeKeyChars(key string, stopChars map[strRune]bool) string {
+	var sb strings.Builder
+	for _, r := range key {
+		if r == '\\' || isStopChar(strRune(r), stopChars) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// Append a map key to the path.
+func appendKey(path, key string) string {
+	if path == "" {
+		return escapeKey(key)
+	}
+	return path + "." + escapeKey(key)
+}
+
+// Append an array index to the path.
+func appendIndex(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+// Append an array index range to the path.
+func appendRange(path string, first, last int) string {
+	return path + "[" + strconv.Itoa(first) + ":" + strconv.Itoa(last) + "]"
+}
+
+// Append a map key written in square brackets to the path.
+func appendBracketKey(path, key string) string {
+	return path + "[" + key + "]"
+}
+
+// Check that the input is not empty and contains no unescaped characters
+// having special meaning in a path. Commas are allowed, separating bare words
+// as they separate assignments.
+func isBareWord(str string, stopChars map[strRune]bool) bool {
+	escaped := false
+	for _, r := range str {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r != ',' && isStopChar(strRune(r), stopChars):
+			return false
+		}
+	}
+	return str != ""
+}
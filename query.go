@@ -0,0 +1,66 @@
+package djson
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MergeQuery deserializes the assignments of the input string in the query
+// string format, e.g. "a.b=1&c[0]=x", and merges them to the map provided
+// in order like MergeValue does. The keys and the values are percent-decoded
+// before being parsed, with '+' decoded to a space if WithPlusAsSpace is set.
+// Commas never separate assignments, and only the literal ones of a value in
+// literal curly braces separate the elements of a brace list, e.g. "a={x,y}".
+func MergeQuery(m map[string]interface{}, query string, opts ...Option) error {
+	o := newOptions(opts)
+	unescape := url.PathUnescape
+	if o.plusAsSpace {
+		unescape = url.QueryUnescape
+	}
+	assignment := string(o.syntax.Assignment)
+	for _, part := range strings.Split(query, "&") {
+		if part == "" {
+			continue
+		}
+		key, val := part, ""
+		if i := strings.Index(part, "="); i >= 0 {
+			key, val = part[:i], part[i+1:]
+		}
+		key, err := unescape(key)
+		if err != nil {
+			return fmt.Errorf("unable to decode \"%s\", %v", part, err)
+		}
+		if val, err = queryValue(val, unescape); err != nil {
+			return fmt.Errorf("unable to decode \"%s\", %v", part, err)
+		}
+		// A decoded assignment operator belongs to the key rather than
+		// separating the value, and decoded commas do not separate assignments
+		key = escapeCommas(strings.ReplaceAll(key, assignment, "\\"+assignment))
+		if err := MergeValue(m, key+assignment+val, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode the value of a query string part rendering it, so that it is lexed
+// literally and does not start a new assignment. The literal commas of a
+// value enclosed in literal curly braces separate the elements of a brace
+// list, while the other commas, including the percent-encoded ones, belong to
+// the value or the element.
+func queryValue(raw string, unescape func(string) (string, error)) (string, error) {
+	if !isBraced(raw) {
+		val, err := unescape(raw)
+		return renderValue(val), err
+	}
+	items := strings.Split(raw[1:len(raw)-1], ",")
+	for i, item := range items {
+		val, err := unescape(item)
+		if err != nil {
+			return "", err
+		}
+		items[i] = escapeBraceItem(val)
+	}
+	return "{" + strings.Join(items, ",") + "}", nil
+}
@@ -0,0 +1,161 @@
+package djson
+
+import (
+	"testing"
+)
+
+func Test_MergeQuery_Succeeds(t *testing.T) {
+	testCases := []struct {
+		parserTestCase
+		opts []Option
+	}{
+		{
+			newParserTestCase(
+				"a nested key and an array index", "a.b=5&c[0]=x&c[1]=y",
+				map[string]interface{}{
+					"a": map[string]interface{}{
+						"b": int64(5),
+					},
+					"c": []interface{}{"x", "y"},
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"percent-encoded characters", "a%5B0%5D%2Eb%3Dc=x%26y%3Dz&d=100%25",
+				map[string]interface{}{
+					"a": []interface{}{
+						map[string]interface{}{
+							"b=c": "x&y=z",
+						},
+					},
+					"d": "100%",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"empty parts and a missing value", "&a=&b&",
+				map[string]interface{}{
+					"a": "",
+					"b": "",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"commas in a key and a value", "a%2Cb=x,y",
+				map[string]interface{}{
+					"a,b": "x,y",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"a brace list", "a={x,y}",
+				map[string]interface{}{
+					"a": []interface{}{"x", "y"},
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"a percent-encoded comma", "a=x%2Cy",
+				map[string]interface{}{
+					"a": "x,y",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"a percent-encoded comma in a brace list", "a={x%2Cy,z}",
+				map[string]interface{}{
+					"a": []interface{}{"x,y", "z"},
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"a literal plus", "a=x+y",
+				map[string]interface{}{
+					"a": "x+y",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"a plus decoded to a space", "a=x+y",
+				map[string]interface{}{
+					"a": "x y",
+				},
+			),
+			[]Option{WithPlusAsSpace()},
+		},
+		{
+			newParserTestCase(
+				"a decoded backslash", "path=C:%5Cdir",
+				map[string]interface{}{
+					"path": "C:\\dir",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"a decoded bracket and comma", "a=x%5By,z",
+				map[string]interface{}{
+					"a": "x[y,z",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"a decoded brace and backslash", "a=x%7By%5Cz",
+				map[string]interface{}{
+					"a": "x{y\\z",
+				},
+			),
+			nil,
+		},
+		{
+			newParserTestCase(
+				"decoded braces and backslashes in a brace list", "a={x%7By%7D,%5Cz}",
+				map[string]interface{}{
+					"a": []interface{}{"x{y}", "\\z"},
+				},
+			),
+			nil,
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeQuery(m, test.input, test.opts...)
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+}
+
+func Test_MergeQuery_Fails(t *testing.T) {
+	testCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an invalid percent-encoding", "a=1&b=%zz",
+			"unable to decode \"b=%zz\", invalid URL escape \"%zz\"",
+		),
+		newParserErrorTestCase(
+			"an invalid path", "a.=1",
+			"unable to parse \"a.=1\", in position 3 got unexpected character: U+003D '=', expecting a map key",
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		assertError(t, MergeQuery(m, test.input), test)
+	}
+}
@@ -0,0 +1,114 @@
+package djson
+
+import (
+	"fmt"
+	"strings"
+)
+
+const referencePrefix = "@ref:"
+
+// Reference is a placeholder for the value at the path written in the DJSON
+// syntax, replaced with the value by ResolveReferences.
+type Reference struct {
+	Path string
+}
+
+// Parse the value as a reference if it has the reference prefix.
+func parseReference(val string) (Reference, bool) {
+	if !strings.HasPrefix(val, referencePrefix) {
+		return Reference{}, false
+	}
+	return Reference{Path: val[len(referencePrefix):]}, true
+}
+
+// ResolveReferences replaces the references in the map provided with the
+// values at their paths in the same map, following references to references.
+// It fails if a reference is cyclic or its path is not present in the map.
+func ResolveReferences(m map[string]interface{}) error {
+	for _, key := range SortedKeys(m) {
+		val, err := resolveValue(m, appendKey("", key), m[key], map[string]bool{})
+		if err != nil {
+			return err
+		}
+		m[key] = val
+	}
+	return nil
+}
+
+// Resolve the references in the value, the visited references being the ones
+// the value is reached through.
+func resolveValue(root map[string]interface{}, path string, val interface{}, visited map[string]bool) (interface{}, error) {
+	switch v := val.(type) {
+	case Reference:
+		if !visited[path] {
+			// A reference to the path of the reference itself is cyclic
+			visited[path] = true
+			defer delete(visited, path)
+		}
+		return resolveReference(root, path, v, visited)
+	case map[string]interface{}:
+		for _, key := range SortedKeys(v) {
+			res, err := resolveValue(root, appendKey(path, key), v[key], visited)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = res
+		}
+	case []interface{}:
+		for i, e := range v {
+			res, err := resolveValue(root, appendIndex(path, i), e, visited)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = res
+		}
+	}
+	return val, nil
+}
+
+func resolveReference(root map[string]interface{}, path string, ref Reference, visited map[string]bool) (interface{}, error) {
+	if visited[ref.Path] {
+		return nil, fmt.Errorf("cyclic reference \"%s\" at path \"%s\"", ref.Path, path)
+	}
+	visited[ref.Path] = true
+	defer delete(visited, ref.Path)
+	val, ok := lookup(root, ref.Path)
+	if !ok {
+		return nil, fmt.Errorf("dangling reference \"%s\" at path \"%s\"", ref.Path, path)
+	}
+	if next, ok := val.(Reference); ok {
+		return resolveReference(root, path, next, visited)
+	}
+	return resolveValue(root, ref.Path, val, visited)
+}
+
+// Look up the value at the path written in the DJSON syntax.
+func lookup(m map[string]interface{}, path string) (interface{}, bool) {
+	n, err := ParseAST(path + "=")
+	if err != nil {
+		return nil, false
+	}
+	var val interface{} = m
+	for {
+		switch node := n.(type) {
+		case *KeyNode:
+			mm, ok := val.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if val, ok = mm[node.Key]; !ok {
+				return nil, false
+			}
+			n = node.Next
+		case *IndexNode:
+			a, ok := val.([]interface{})
+			if !ok || node.Index >= len(a) {
+				return nil, false
+			}
+			val = a[node.Index]
+			n = node.Next
+		default:
+			return val, true
+		}
+	}
+}
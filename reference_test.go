@@ -0,0 +1,77 @@
+package djson
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ResolveReferences_Replaces_References(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a simple reference", "services.db.host=db1,target=@ref:services.db.host",
+			map[string]interface{}{
+				"services": map[string]interface{}{
+					"db": map[string]interface{}{
+						"host": "db1",
+					},
+				},
+				"target": "db1",
+			},
+		),
+		newParserTestCase(
+			"a chained reference", "a=@ref:b[1],b[1]=@ref:c.d,c.d=10",
+			map[string]interface{}{
+				"a": int64(10),
+				"b": []interface{}{nil, int64(10)},
+				"c": map[string]interface{}{
+					"d": int64(10),
+				},
+			},
+		),
+		newParserTestCase(
+			"a reference to a map with references", "a=@ref:b,b.c=@ref:d,d=x",
+			map[string]interface{}{
+				"a": map[string]interface{}{
+					"c": "x",
+				},
+				"b": map[string]interface{}{
+					"c": "x",
+				},
+				"d": "x",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			if err := MergeValue(m, part, WithReferences()); err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, ResolveReferences(m), test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a cyclic reference", "a=@ref:b,b=@ref:c,c=@ref:a",
+			"cyclic reference \"a\" at path \"a\"",
+		),
+		newParserErrorTestCase(
+			"a reference to its own parent", "a.b=@ref:a",
+			"cyclic reference \"a\" at path \"a.b\"",
+		),
+		newParserErrorTestCase(
+			"a dangling reference", "a=@ref:b.c,b=x",
+			"dangling reference \"b.c\" at path \"a\"",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			if err := MergeValue(m, part, WithReferences()); err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertError(t, ResolveReferences(m), test)
+	}
+}
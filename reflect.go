@@ -0,0 +1,266 @@
+package djson
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// MergeValueReflect deserializes the input string and writes the value to the
+// field at the path in the structure the pointer provided points to. Map keys
+// in the path select the exported struct fields by their names, ignoring the
+// case, or the entries of maps with string keys, and array indices select the
+// elements of slices, which grow as needed, or arrays, every element of an
+// index range receiving the value. Pointers are allocated on the way. The
+// value is converted to the type of the destination, e.g. an integer, a
+// boolean or a time.Duration, lists like brace lists replace slices with
+// their elements converted one by one, and destinations of interface types
+// receive the value converted as MergeValue does.
+func MergeValueReflect(ptr interface{}, str string, opts ...Option) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("unable to parse \"%s\", expecting a non-nil pointer, got %T", str, ptr)
+	}
+	parser := newParser(str, opts)
+	parser.rightValueReader = parser.readRightString
+	n, err := parser.parseAST(str)
+	if err != nil {
+		return err
+	}
+	if err := assignReflect(v.Elem(), "", n); err != nil {
+		return fmt.Errorf("unable to parse \"%s\", %v", str, err)
+	}
+	return nil
+}
+
+func assignReflect(v reflect.Value, path string, n Node) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assignReflect(v.Elem(), path, n)
+	}
+	switch n := n.(type) {
+	case *KeyNode:
+		return assignKey(v, appendKey(path, n.Key), n)
+	case *IndexNode:
+		return assignIndex(v, appendIndex(path, n.Index), n)
+	case *RangeNode:
+		return assignRange(v, path, n)
+	case *ValueNode:
+		return assignParsed(v, path, n.Value)
+	default:
+		return fmt.Errorf("missing value at path \"%s\"", path)
+	}
+}
+
+func assignKey(v reflect.Value, path string, n *KeyNode) error {
+	switch v.Kind() {
+	case reflect.Struct:
+		f := v.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, n.Key)
+		})
+		if !f.IsValid() || !f.CanSet() {
+			return fmt.Errorf("no field at path \"%s\" in %s", path, v.Type())
+		}
+		return assignReflect(f, path, n.Next)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unable to index %s with the key at path \"%s\"", v.Type(), path)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(n.Key).Convert(v.Type().Key())
+		// Map elements are not addressable, so the element is written back
+		e := reflect.New(v.Type().Elem()).Elem()
+		if old := v.MapIndex(key); old.IsValid() {
+			e.Set(old)
+		}
+		if err := assignReflect(e, path, n.Next); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, e)
+		return nil
+	case reflect.Interface:
+		return assignInterface(v, path, n)
+	default:
+		return fmt.Errorf("unable to set the key at path \"%s\" in %s", path, v.Type())
+	}
+}
+
+func assignIndex(v reflect.Value, path string, n *IndexNode) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		if n.Index >= v.Len() {
+			grown := reflect.MakeSlice(v.Type(), n.Index+1, n.Index+1)
+			reflect.Copy(grown, v)
+			v.Set(grown)
+		}
+	case reflect.Array:
+		if n.Index >= v.Len() {
+			return fmt.Errorf("index at path \"%s\" is out of the bounds of %s", path, v.Type())
+		}
+	case reflect.Interface:
+		return assignInterface(v, path, n)
+	default:
+		return fmt.Errorf("unable to set the index at path \"%s\" in %s", path, v.Type())
+	}
+	return assignReflect(v.Index(n.Index), path, n.Next)
+}
+
+// Assign the rest of the path to every index of the range.
+func assignRange(v reflect.Value, path string, n *RangeNode) error {
+	if v.Kind() == reflect.Interface {
+		return assignInterface(v, appendRange(path, n.First, n.Last), n)
+	}
+	for i := n.First; i <= n.Last; i++ {
+		if err := assignIndex(v, appendIndex(path, i), &IndexNode{Index: i, Next: n.Next}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assign the rest of the path to an interface merging it the way MergeValue
+// does into the map or the array the interface holds.
+func assignInterface(v reflect.Value, path string, n Node) error {
+	var cur interface{}
+	if !v.IsNil() {
+		cur = v.Elem().Interface()
+	}
+	holder := map[string]interface{}{"": cur}
+	b := newRootBuilder(holder).newMapBuilder("")
+	if err := evaluate(b, convertNodes(n)); err != nil {
+		return fmt.Errorf("%v at path \"%s\"", err, path)
+	}
+	return assignConverted(v, path, holder[""])
+}
+
+// Assign a value read as a string, or as a list of strings, e.g. a brace
+// list, converting the elements to the element type of a slice or an array.
+func assignParsed(v reflect.Value, path string, val interface{}) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assignParsed(v.Elem(), path, val)
+	}
+	switch val := val.(type) {
+	case string:
+		return assignValue(v, path, val)
+	case []interface{}:
+		switch v.Kind() {
+		case reflect.Slice:
+			v.Set(reflect.MakeSlice(v.Type(), len(val), len(val)))
+		case reflect.Array:
+			if len(val) > v.Len() {
+				return fmt.Errorf("list at path \"%s\" is out of the bounds of %s", path, v.Type())
+			}
+		case reflect.Interface:
+			return assignConverted(v, path, convertValue(val))
+		default:
+			return fmt.Errorf("unable to set the list at path \"%s\" to %s", path, v.Type())
+		}
+		for i, e := range val {
+			if err := assignParsed(v.Index(i), appendIndex(path, i), e); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return assignConverted(v, path, val)
+	}
+}
+
+// Assign a value which is not a string if its type is assignable.
+func assignConverted(v reflect.Value, path string, val interface{}) error {
+	if val == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	if !rv.Type().AssignableTo(v.Type()) {
+		return fmt.Errorf("unable to set %s at path \"%s\" to %s", rv.Type(), path, v.Type())
+	}
+	v.Set(rv)
+	return nil
+}
+
+// Copy the nodes converting the string values the way MergeValue does.
+func convertNodes(n Node) Node {
+	switch n := n.(type) {
+	case *KeyNode:
+		return &KeyNode{Key: n.Key, Next: convertNodes(n.Next)}
+	case *IndexNode:
+		return &IndexNode{Index: n.Index, Next: convertNodes(n.Next)}
+	case *RangeNode:
+		return &RangeNode{First: n.First, Last: n.Last, Next: convertNodes(n.Next)}
+	case *ValueNode:
+		return &ValueNode{Value: convertValue(n.Value)}
+	default:
+		return n
+	}
+}
+
+// Convert the strings of the value the way MergeValue does, including the
+// elements of lists.
+func convertValue(val interface{}) interface{} {
+	switch val := val.(type) {
+	case string:
+		return tryParse(val, &defaultLiterals)
+	case []interface{}:
+		a := make([]interface{}, len(val))
+		for i, e := range val {
+			a[i] = convertValue(e)
+		}
+		return a
+	default:
+		return val
+	}
+}
+
+func assignValue(v reflect.Value, path, val string) error {
+	var err error
+	switch {
+	case v.Type() == durationType:
+		var d time.Duration
+		if d, err = time.ParseDuration(val); err == nil {
+			v.SetInt(int64(d))
+		}
+	case v.Kind() == reflect.String:
+		v.SetString(val)
+	case v.Kind() == reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(val); err == nil {
+			v.SetBool(b)
+		}
+	case v.Kind() >= reflect.Int && v.Kind() <= reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(val, 10, v.Type().Bits()); err == nil {
+			v.SetInt(i)
+		}
+	case v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(val, 10, v.Type().Bits()); err == nil {
+			v.SetUint(u)
+		}
+	case v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(val, v.Type().Bits()); err == nil {
+			v.SetFloat(f)
+		}
+	case v.Kind() == reflect.Interface:
+		return assignConverted(v, path, tryParse(val, &defaultLiterals))
+	default:
+		return fmt.Errorf("unable to set the value at path \"%s\" to %s", path, v.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("value \"%s\" at path \"%s\" is not a valid %s", val, path, v.Type())
+	}
+	return nil
+}
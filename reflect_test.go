@@ -0,0 +1,168 @@
+package djson
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type reflectServer struct {
+	Host    string
+	Port    int
+	Enabled *bool
+	Timeout time.Duration
+}
+
+type reflectConfig struct {
+	Name    string
+	Ratio   float32
+	Server  reflectServer
+	Backup  *reflectServer
+	Tags    []string
+	Ports   []int
+	Servers []reflectServer
+	Labels  map[string]string
+	Limits  map[string]*reflectServer
+	Extra   interface{}
+	private string
+}
+
+func Test_MergeValueReflect_Succeeds(t *testing.T) {
+	enabled := true
+	testCases := []struct {
+		desc     string
+		inputs   []string
+		expected reflectConfig
+	}{
+		{
+			"a nested struct field", []string{"server.host=db1", "Server.Port=5432"},
+			reflectConfig{Server: reflectServer{Host: "db1", Port: 5432}},
+		},
+		{
+			"allocated pointers", []string{"backup.enabled=true", "backup.timeout=1m30s"},
+			reflectConfig{Backup: &reflectServer{Enabled: &enabled, Timeout: 90 * time.Second}},
+		},
+		{
+			"a slice field with indexing", []string{"tags[1]=b", "tags[0]=a", "servers[1].port=80"},
+			reflectConfig{
+				Tags:    []string{"a", "b"},
+				Servers: []reflectServer{{}, {Port: 80}},
+			},
+		},
+		{
+			"brace lists", []string{"tags={a,b}", "ports={80,443}", "extra.y={a,5}"},
+			reflectConfig{
+				Tags:  []string{"a", "b"},
+				Ports: []int{80, 443},
+				Extra: map[string]interface{}{
+					"y": []interface{}{"a", int64(5)},
+				},
+			},
+		},
+		{
+			"map fields", []string{"labels.env=prod", "limits.cpu.port=2", "limits.cpu.host=x"},
+			reflectConfig{
+				Labels: map[string]string{"env": "prod"},
+				Limits: map[string]*reflectServer{"cpu": {Host: "x", Port: 2}},
+			},
+		},
+		{
+			"an interface field", []string{"extra.a[1]=10", "extra.b=true", "name=10", "ratio=0.5"},
+			reflectConfig{
+				Name:  "10",
+				Ratio: 0.5,
+				Extra: map[string]interface{}{
+					"a": []interface{}{nil, int64(10)},
+					"b": true,
+				},
+			},
+		},
+	}
+	for _, test := range testCases {
+		var c reflectConfig
+		for _, input := range test.inputs {
+			if err := MergeValueReflect(&c, input); err != nil {
+				t.Fatalf("In the case of %s expected success for \"%s\", got %v", test.desc, input, err)
+			}
+		}
+		if !reflect.DeepEqual(c, test.expected) {
+			t.Errorf("\nIn the case of %s\nexpected:\n\t%+v\ngot:\n\t%+v", test.desc, test.expected, c)
+		}
+	}
+
+	var c reflectConfig
+	for _, input := range []string{"tags[0:2]=x", "servers[0:1].port=80", "extra[1:2]=10"} {
+		if err := MergeValueReflect(&c, input, WithIndexRanges()); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", input, err)
+		}
+	}
+	expected := reflectConfig{
+		Tags:    []string{"x", "x", "x"},
+		Servers: []reflectServer{{Port: 80}, {Port: 80}},
+		Extra:   []interface{}{nil, int64(10), int64(10)},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("\nIn the case of index ranges\nexpected:\n\t%+v\ngot:\n\t%+v", expected, c)
+	}
+
+	c = reflectConfig{}
+	for _, input := range []string{"tags=a;b", "extra=x;5"} {
+		if err := MergeValueReflect(&c, input, WithGlobalValueSplit(';')); err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", input, err)
+		}
+	}
+	expected = reflectConfig{
+		Tags:  []string{"a", "b"},
+		Extra: []interface{}{"x", int64(5)},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("\nIn the case of split values\nexpected:\n\t%+v\ngot:\n\t%+v", expected, c)
+	}
+}
+
+func Test_MergeValueReflect_Fails(t *testing.T) {
+	testCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a type mismatch", "server.port=abc",
+			"unable to parse \"server.port=abc\", value \"abc\" at path \"server.port\" is not a valid int",
+		),
+		newParserErrorTestCase(
+			"a missing field", "server.user=x",
+			"unable to parse \"server.user=x\", no field at path \"server.user\" in djson.reflectServer",
+		),
+		newParserErrorTestCase(
+			"an unexported field", "private=x",
+			"unable to parse \"private=x\", no field at path \"private\" in djson.reflectConfig",
+		),
+		newParserErrorTestCase(
+			"a key in a slice", "tags.a=x",
+			"unable to parse \"tags.a=x\", unable to set the key at path \"tags.a\" in []string",
+		),
+		newParserErrorTestCase(
+			"an index in a struct", "server[0]=x",
+			"unable to parse \"server[0]=x\", unable to set the index at path \"server[0]\" in djson.reflectServer",
+		),
+		newParserErrorTestCase(
+			"a list element type mismatch", "ports={80,x}",
+			"unable to parse \"ports={80,x}\", value \"x\" at path \"ports[1]\" is not a valid int",
+		),
+		newParserErrorTestCase(
+			"a list in a struct", "server={a,b}",
+			"unable to parse \"server={a,b}\", unable to set the list at path \"server\" to djson.reflectServer",
+		),
+		newParserErrorTestCase(
+			"an invalid path", "server.=x",
+			"unable to parse \"server.=x\", in position 8 got unexpected character: U+003D '=', expecting a map key",
+		),
+	}
+	for _, test := range testCases {
+		var c reflectConfig
+		assertError(t, MergeValueReflect(&c, test.input), test)
+	}
+
+	errorTest := newParserErrorTestCase(
+		"a non-pointer", "a=1",
+		"unable to parse \"a=1\", expecting a non-nil pointer, got djson.reflectConfig",
+	)
+	assertError(t, MergeValueReflect(reflectConfig{}, errorTest.input), errorTest)
+}
@@ -0,0 +1,92 @@
+package djson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of a value declared in a schema.
+type Type int
+
+const (
+	// TypeBool declares a boolean value. Apart from the literals recognized
+	// by MergeValue it accepts "1", "0", "yes" and "no".
+	TypeBool Type = iota
+)
+
+// Schema declares the types of the values at the paths written in the DJSON
+// syntax, e.g. "servers[0].enabled". The values at the declared paths are
+// coerced to the declared types instead of the usual conversion.
+type Schema map[string]Type
+
+// Coerce the value at the path to the type declared in a schema. Numeric
+// booleans are any numbers, true unless zero.
+func coerce(path, val string, t Type, numericBools bool) (interface{}, error) {
+	switch t {
+	case TypeBool:
+		if numericBools {
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				return f != 0, nil
+			}
+		}
+		if b, ok := parseSchemaBool(val); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("value \"%s\" at path \"%s\" is not a boolean", val, path)
+	default:
+		return nil, fmt.Errorf("unknown type %d declared for path \"%s\"", t, path)
+	}
+}
+
+// Coerce the value at a boolean path to a tristate boolean, the unknown
+// value being a nil pointer.
+func coerceTristate(path, val string, numericBools bool) (*bool, error) {
+	switch strings.ToLower(val) {
+	case "unknown", "maybe", "null":
+		return nil, nil
+	}
+	v, err := coerce(path, val, TypeBool, numericBools)
+	if err != nil {
+		return nil, err
+	}
+	b := v.(bool)
+	return &b, nil
+}
+
+func parseSchemaBool(val string) (bool, bool) {
+	if b, err := strconv.ParseBool(val); err == nil {
+		return b, true
+	}
+	switch strings.ToLower(val) {
+	case "yes":
+		return true, true
+	case "no":
+		return false, true
+	}
+	return false, false
+}
+
+// Parse the value at the path as a time following the layout declared for
+// the path.
+func parseTime(path, val, layout string) (time.Time, error) {
+	t, err := time.Parse(layout, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("value \"%s\" at path \"%s\" does not match the time layout \"%s\"", val, path, layout)
+	}
+	return t, nil
+}
+
+// Parse the value as an integer Unix time counted in the unit provided.
+func parseUnixTime(val string, unit time.Duration) (time.Time, bool) {
+	i, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || unit <= 0 {
+		return time.Time{}, false
+	}
+	if unit >= time.Second {
+		return time.Unix(i*int64(unit/time.Second), 0).UTC(), true
+	}
+	perSecond := int64(time.Second / unit)
+	return time.Unix(i/perSecond, i%perSecond*int64(unit)).UTC(), true
+}
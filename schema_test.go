@@ -0,0 +1,303 @@
+package djson
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_Parser_Coerces_Schema_Booleans(t *testing.T) {
+	schema := Schema{
+		"enabled":        TypeBool,
+		"features[0].on": TypeBool,
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"one at a boolean path", "enabled=1",
+			map[string]interface{}{
+				"enabled": true,
+			},
+		),
+		newParserTestCase(
+			"zero at a boolean path", "enabled=0",
+			map[string]interface{}{
+				"enabled": false,
+			},
+		),
+		newParserTestCase(
+			"yes at a boolean path", "features[0].on=yes",
+			map[string]interface{}{
+				"features": []interface{}{
+					map[string]interface{}{
+						"on": true,
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"no at a boolean path", "enabled=No",
+			map[string]interface{}{
+				"enabled": false,
+			},
+		),
+		newParserTestCase(
+			"two at an unannotated path", "count=2",
+			map[string]interface{}{
+				"count": int64(2),
+			},
+		),
+		newParserTestCase(
+			"yes at an unannotated path", "answer=yes",
+			map[string]interface{}{
+				"answer": "yes",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithSchema(schema))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a non-boolean at a boolean path", "enabled=2",
+			"unable to parse \"enabled=2\", value \"2\" at path \"enabled\" is not a boolean",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithSchema(schema))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Coerces_Numeric_Schema_Booleans(t *testing.T) {
+	schema := Schema{
+		"active": TypeBool,
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a positive number at a boolean path", "active=5",
+			map[string]interface{}{
+				"active": true,
+			},
+		),
+		newParserTestCase(
+			"a negative float at a boolean path", "active=-0.5",
+			map[string]interface{}{
+				"active": true,
+			},
+		),
+		newParserTestCase(
+			"zero at a boolean path", "active=0",
+			map[string]interface{}{
+				"active": false,
+			},
+		),
+		newParserTestCase(
+			"a number at an unannotated path", "count=5",
+			map[string]interface{}{
+				"count": int64(5),
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithSchema(schema), WithNumericBools())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a non-numeric value at a boolean path", "active=high",
+			"unable to parse \"active=high\", value \"high\" at path \"active\" is not a boolean",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithSchema(schema), WithNumericBools())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Coerces_Tristate_Schema_Booleans(t *testing.T) {
+	schema := Schema{
+		"active": TypeBool,
+	}
+	yes, no := true, false
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"true at a boolean path", "active=true",
+			map[string]interface{}{
+				"active": &yes,
+			},
+		),
+		newParserTestCase(
+			"false at a boolean path", "active=no",
+			map[string]interface{}{
+				"active": &no,
+			},
+		),
+		newParserTestCase(
+			"unknown at a boolean path", "active=unknown",
+			map[string]interface{}{
+				"active": (*bool)(nil),
+			},
+		),
+		newParserTestCase(
+			"maybe in upper case at a boolean path", "active=MAYBE",
+			map[string]interface{}{
+				"active": (*bool)(nil),
+			},
+		),
+		newParserTestCase(
+			"null at a boolean path", "active=null",
+			map[string]interface{}{
+				"active": (*bool)(nil),
+			},
+		),
+		newParserTestCase(
+			"unknown at an unannotated path", "state=unknown",
+			map[string]interface{}{
+				"state": "unknown",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithSchema(schema), WithTristateBools())
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an invalid value at a boolean path", "active=perhaps",
+			"unable to parse \"active=perhaps\", value \"perhaps\" at path \"active\" is not a boolean",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithSchema(schema), WithTristateBools())
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Parses_Times_With_Layouts(t *testing.T) {
+	layouts := map[string]string{
+		"date":          "01/02/2006",
+		"events[0].at":  time.RFC3339,
+		"backup.window": "15:04",
+	}
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a US-format date", "date=12/31/2021",
+			map[string]interface{}{
+				"date": time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC),
+			},
+		),
+		newParserTestCase(
+			"an RFC 3339 time in an array", "events[0].at=2021-06-01T10:30:00Z",
+			map[string]interface{}{
+				"events": []interface{}{
+					map[string]interface{}{
+						"at": time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC),
+					},
+				},
+			},
+		),
+		newParserTestCase(
+			"a date at an undeclared path", "other=12/31/2021",
+			map[string]interface{}{
+				"other": "12/31/2021",
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithTimeLayouts(layouts))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"a mismatched format", "date=2021-12-31",
+			"unable to parse \"date=2021-12-31\", value \"2021-12-31\" at path \"date\" does not match the time layout \"01/02/2006\"",
+		),
+		newParserErrorTestCase(
+			"an invalid time", "backup.window=25:00",
+			"unable to parse \"backup.window=25:00\", value \"25:00\" at path \"backup.window\" does not match the time layout \"15:04\"",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithTimeLayouts(layouts))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Parser_Parses_Unix_Times(t *testing.T) {
+	testCases := []struct {
+		parserTestCase
+		unit time.Duration
+	}{
+		{
+			newParserTestCase(
+				"Unix seconds", "created=1609459200",
+				map[string]interface{}{
+					"created": time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+				},
+			),
+			time.Second,
+		},
+		{
+			newParserTestCase(
+				"Unix milliseconds", "created=1609459200250",
+				map[string]interface{}{
+					"created": time.Date(2021, 1, 1, 0, 0, 0, 250000000, time.UTC),
+				},
+			),
+			time.Millisecond,
+		},
+		{
+			newParserTestCase(
+				"negative Unix seconds", "created=-86400",
+				map[string]interface{}{
+					"created": time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC),
+				},
+			),
+			time.Second,
+		},
+		{
+			newParserTestCase(
+				"an integer at an undeclared path", "count=1609459200",
+				map[string]interface{}{
+					"count": int64(1609459200),
+				},
+			),
+			time.Second,
+		},
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithUnixTimeParsing(test.unit, "created"))
+		assertNoError(t, err, test.parserTestCase, m)
+	}
+
+	test := newParserTestCase(
+		"a non-integer value falling back to a time layout", "created=12/31/2021",
+		map[string]interface{}{
+			"created": time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+	)
+	m := map[string]interface{}{}
+	err := MergeValue(m, test.input, WithUnixTimeParsing(time.Second, "created"),
+		WithTimeLayouts(map[string]string{"created": "01/02/2006"}))
+	assertNoError(t, err, test, m)
+
+	errorTest := newParserErrorTestCase(
+		"a non-integer value without a time layout", "created=yesterday",
+		"unable to parse \"created=yesterday\", value \"yesterday\" at path \"created\" is not a Unix time",
+	)
+	m = map[string]interface{}{}
+	assertError(t, MergeValue(m, errorTest.input, WithUnixTimeParsing(time.Second, "created")), errorTest)
+}
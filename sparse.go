@@ -0,0 +1,87 @@
+package djson
+
+// SparseArray is an array built from indices too scattered to be stored
+// densely, mapping the assigned indices to their elements.
+type SparseArray map[int]interface{}
+
+type sparseArrayBuilder struct {
+	a      SparseArray
+	index  int
+	parent setter
+}
+
+// Create a builder of the sparse array stored by the builder provided,
+// converting a dense array stored there to a sparse one.
+func newSparseArrayBuilder(b builder, index int) builder {
+	val, _ := b.get()
+	return &sparseArrayBuilder{a: toSparseArray(val), index: index, parent: b}
+}
+
+func toSparseArray(val interface{}) SparseArray {
+	switch v := val.(type) {
+	case SparseArray:
+		return v
+	case []interface{}:
+		a := make(SparseArray, len(v))
+		for i, e := range v {
+			a[i] = e
+		}
+		return a
+	default:
+		return SparseArray{}
+	}
+}
+
+func (b *sparseArrayBuilder) newMapBuilder(key string) builder {
+	if m, ok := b.a[b.index].(map[string]interface{}); ok {
+		return &mapBuilder{m: m, key: key, parent: b}
+	}
+	m := map[string]interface{}{}
+	return &mapBuilder{m: m, key: key, parent: b}
+}
+
+func (b *sparseArrayBuilder) newArrayBuilder(index int) builder {
+	return newSparseArrayBuilder(b, index)
+}
+
+func (b *sparseArrayBuilder) get() (interface{}, bool) {
+	val, ok := b.a[b.index]
+	return val, ok
+}
+
+// The array is stored densely as soon as its indices leave no gaps, so that
+// only arrays which are actually sparse are represented by SparseArray.
+func (b *sparseArrayBuilder) set(val interface{}) {
+	b.a[b.index] = val
+	b.store()
+}
+
+// Deleting an element leaves a gap instead of shifting the following ones,
+// as the indices of a sparse array are its keys.
+func (b *sparseArrayBuilder) delete() {
+	if _, ok := b.a[b.index]; ok {
+		delete(b.a, b.index)
+		b.store()
+	}
+}
+
+func (b *sparseArrayBuilder) store() {
+	if dense, ok := b.a.dense(); ok {
+		b.parent.set(dense)
+		return
+	}
+	b.parent.set(b.a)
+}
+
+// Convert the array to a dense one if its indices leave no gaps.
+func (a SparseArray) dense() ([]interface{}, bool) {
+	dense := make([]interface{}, 0, len(a))
+	for i := 0; i < len(a); i++ {
+		e, ok := a[i]
+		if !ok {
+			return nil, false
+		}
+		dense = append(dense, e)
+	}
+	return dense, true
+}
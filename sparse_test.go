@@ -0,0 +1,63 @@
+package djson
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Parser_Builds_Sparse_Arrays(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a huge index", "foo[1000000]=x",
+			map[string]interface{}{
+				"foo": SparseArray{1000000: "x"},
+			},
+		),
+		newParserTestCase(
+			"indices leaving no gaps", "foo[1]=b,foo[0]=a",
+			map[string]interface{}{
+				"foo": []interface{}{"a", "b"},
+			},
+		),
+		newParserTestCase(
+			"nested sparse arrays", "foo[10][20]=x",
+			map[string]interface{}{
+				"foo": SparseArray{
+					10: SparseArray{20: "x"},
+				},
+			},
+		),
+		newParserTestCase(
+			"maps in sparse arrays", "foo[10].a=x,foo[10].b=y",
+			map[string]interface{}{
+				"foo": SparseArray{
+					10: map[string]interface{}{
+						"a": "x",
+						"b": "y",
+					},
+				},
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		for _, part := range strings.Split(test.input, ",") {
+			err := MergeValue(m, part, WithSparseArrays())
+			if err != nil {
+				t.Fatalf("Expected success for \"%s\", got %v", part, err)
+			}
+		}
+		assertNoError(t, nil, test, m)
+	}
+
+	test := newParserTestCase(
+		"an existing dense array", "foo[100]=z",
+		map[string]interface{}{
+			"foo": SparseArray{0: "x", 1: "y", 100: "z"},
+		},
+	)
+	m := map[string]interface{}{
+		"foo": []interface{}{"x", "y"},
+	}
+	assertNoError(t, MergeValue(m, test.input, WithSparseArrays()), test, m)
+}
@@ -0,0 +1,79 @@
+package djson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MergeReader merges the assignments read from the reader, one or more
+// comma separated ones per line, to the map provided like MergeValue does.
+// The lines are not limited in length, and empty lines are skipped. The
+// reading stops at the first line failing the parsing, and the assignments
+// merged before it are kept in the map.
+func MergeReader(m map[string]interface{}, r io.Reader, opts ...Option) error {
+	return readLines(r, func(line string) error {
+		return MergeValue(m, line, opts...)
+	})
+}
+
+// StreamNDJSON merges the assignments read from the reader, one or more
+// comma separated ones per line, to the map provided like MergeValue does.
+// After every line, it writes every top-level key the line assigned whose
+// value changed, with the current value as a JSON object on a line of its
+// own, e.g. {"db":{"host":"db1"}}. Empty lines are skipped.
+func StreamNDJSON(r io.Reader, w io.Writer, m map[string]interface{}, opts ...Option) error {
+	emitted := map[string][]byte{}
+	return readLines(r, func(line string) error {
+		var keys []string
+		lineOpts := append(append([]Option(nil), opts...), func(o *options) {
+			o.topKeys = &keys
+		})
+		if err := MergeValue(m, line, lineOpts...); err != nil {
+			return err
+		}
+		written := map[string]bool{}
+		for _, key := range keys {
+			if written[key] {
+				continue
+			}
+			written[key] = true
+			b, err := json.Marshal(map[string]interface{}{key: m[key]})
+			if err != nil {
+				return fmt.Errorf("unable to encode \"%s\", %v", key, err)
+			}
+			if bytes.Equal(b, emitted[key]) {
+				continue
+			}
+			emitted[key] = b
+			if _, err := w.Write(append(b, '\n')); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Call the function for every line of the reader which is not empty,
+// stopping at the first error. The lines are not limited in length.
+func readLines(r io.Reader, f func(string) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line != "" {
+			if err := f(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package djson
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_MergeReader_Merges_Lines(t *testing.T) {
+	test := newParserTestCase(
+		"lines of assignments", "db.host=db1,db.port=5432\n\nname=app\r\ntags[1]=b\n",
+		map[string]interface{}{
+			"db": map[string]interface{}{
+				"host": "db1",
+				"port": int64(5432),
+			},
+			"name": "app",
+			"tags": []interface{}{nil, "b"},
+		},
+	)
+	m := map[string]interface{}{}
+	assertNoError(t, MergeReader(m, strings.NewReader(test.input)), test, m)
+
+	test = newParserTestCase(
+		"options applied to every line", "a=yes\nb=no",
+		map[string]interface{}{
+			"a": true,
+			"b": false,
+		},
+	)
+	m = map[string]interface{}{}
+	err := MergeReader(m, strings.NewReader(test.input), WithSchema(Schema{"a": TypeBool, "b": TypeBool}))
+	assertNoError(t, err, test, m)
+
+	long := strings.Repeat("x", 100*1024)
+	m = map[string]interface{}{}
+	if err := MergeReader(m, strings.NewReader("a=5\nlong="+long+"\nb=6")); err != nil {
+		t.Fatalf("Expected success for a line longer than 64 KiB, got %v", err)
+	}
+	expected := map[string]interface{}{"a": int64(5), "long": long, "b": int64(6)}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected the long line to be merged, got %d keys", len(m))
+	}
+}
+
+func Test_MergeReader_Fails(t *testing.T) {
+	test := newParserErrorTestCase(
+		"an invalid assignment", "a=5\nb.=2\nc=3",
+		"unable to parse \"b.=2\", in position 3 got unexpected character: U+003D '=', expecting a map key",
+	)
+	m := map[string]interface{}{}
+	assertError(t, MergeReader(m, strings.NewReader(test.input)), test)
+	expected := map[string]interface{}{"a": int64(5)}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("Expected the assignments before the error to be kept, got %v", m)
+	}
+
+	err := MergeReader(map[string]interface{}{}, failingReader{})
+	if err == nil || err.Error() != "read failed" {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+}
+
+func Test_StreamNDJSON_Writes_Changes(t *testing.T) {
+	input := strings.Join([]string{
+		"db.host=db1",
+		"db.port=5432",
+		"",
+		"name=app",
+		"db.port=5432",
+		"tags[1]=b",
+		"db.host=db2",
+	}, "\n")
+	expected := strings.Join([]string{
+		`{"db":{"host":"db1"}}`,
+		`{"db":{"host":"db1","port":5432}}`,
+		`{"name":"app"}`,
+		`{"tags":[null,"b"]}`,
+		`{"db":{"host":"db2","port":5432}}`,
+	}, "\n") + "\n"
+
+	var w bytes.Buffer
+	m := map[string]interface{}{}
+	if err := StreamNDJSON(strings.NewReader(input), &w, m); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if w.String() != expected {
+		t.Errorf("\nexpected:\n%s\ngot:\n%s", expected, w.String())
+	}
+
+	input = "a=7,b=2\nb=2,c.x=3,c.y=4\na=5,a=7"
+	expected = strings.Join([]string{
+		`{"a":7}`,
+		`{"b":2}`,
+		`{"c":{"x":3,"y":4}}`,
+	}, "\n") + "\n"
+	w.Reset()
+	if err := StreamNDJSON(strings.NewReader(input), &w, map[string]interface{}{}); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if w.String() != expected {
+		t.Errorf("\nexpected:\n%s\ngot:\n%s", expected, w.String())
+	}
+}
+
+func Test_StreamNDJSON_Applies_Options(t *testing.T) {
+	input := "debug\n!debug\nport=8080"
+	expected := strings.Join([]string{
+		`{"debug":true}`,
+		`{"debug":false}`,
+		`{"port":8080}`,
+	}, "\n") + "\n"
+
+	var w bytes.Buffer
+	m := map[string]interface{}{}
+	if err := StreamNDJSON(strings.NewReader(input), &w, m, WithBareKeyBooleans()); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if w.String() != expected {
+		t.Errorf("\nexpected:\n%s\ngot:\n%s", expected, w.String())
+	}
+}
+
+func Test_StreamNDJSON_Fails(t *testing.T) {
+	test := newParserErrorTestCase(
+		"an invalid assignment", "a=5\nb.=2",
+		"unable to parse \"b.=2\", in position 3 got unexpected character: U+003D '=', expecting a map key",
+	)
+	var w bytes.Buffer
+	err := StreamNDJSON(strings.NewReader(test.input), &w, map[string]interface{}{})
+	assertError(t, err, test)
+	if w.String() != "{\"a\":5}\n" {
+		t.Errorf("Expected the assignments before the error to be written, got %q", w.String())
+	}
+
+	err = StreamNDJSON(strings.NewReader("a=1"), failingWriter{}, map[string]interface{}{})
+	if err == nil || err.Error() != "write failed" {
+		t.Errorf("Expected the write error, got %v", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
@@ -0,0 +1,759 @@
+package djson
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const (
+	templatePrefix        = "tpl:"
+	escapedTemplatePrefix = "\\" + templatePrefix
+	nestedPrefix          = "nested:"
+	regexpPrefix          = "re:"
+	escapedRegexpPrefix   = "\\" + regexpPrefix
+)
+
+// Render the value as a template if it has the template prefix.
+func renderTemplate(val string, data interface{}) (string, error) {
+	if strings.HasPrefix(val, escapedTemplatePrefix) {
+		return val[1:], nil
+	}
+	if !strings.HasPrefix(val, templatePrefix) {
+		return val, nil
+	}
+	tpl, err := template.New("value").
+		Option("missingkey=error").
+		Parse(val[len(templatePrefix):])
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Check if the value is enclosed in double quotes.
+func isQuoted(val string) bool {
+	return len(val) >= 2 && val[0] == '"' && val[len(val)-1] == '"'
+}
+
+func containsSpace(val string) bool {
+	return strings.IndexFunc(val, unicode.IsSpace) >= 0
+}
+
+// Parse the value as an IP address or a CIDR notation IP address and mask.
+func parseIP(val string) (interface{}, bool) {
+	if ip := net.ParseIP(val); ip != nil {
+		return ip, true
+	}
+	if _, ipNet, err := net.ParseCIDR(val); err == nil {
+		return ipNet, true
+	}
+	return nil, false
+}
+
+// UUID is a universally unique identifier parsed from its canonical textual
+// representation, e.g. "550e8400-e29b-41d4-a716-446655440000".
+type UUID [16]byte
+
+// String returns the canonical textual representation of the UUID.
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// Parse the value as a UUID in the canonical 8-4-4-4-12 form.
+func parseUUID(val string) (UUID, bool) {
+	var u UUID
+	if len(val) != 36 || val[8] != '-' || val[13] != '-' || val[18] != '-' || val[23] != '-' {
+		return u, false
+	}
+	digits := val[0:8] + val[9:13] + val[14:18] + val[19:23] + val[24:]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return u, false
+	}
+	return u, true
+}
+
+// The multipliers of the quantity suffixes following the Kubernetes
+// resource.Quantity semantics, with "K" accepted as an alias of "k".
+var quantitySuffixes = map[string]*big.Rat{
+	"n":  big.NewRat(1, 1e9),
+	"u":  big.NewRat(1, 1e6),
+	"m":  big.NewRat(1, 1e3),
+	"k":  big.NewRat(1e3, 1),
+	"K":  big.NewRat(1e3, 1),
+	"M":  big.NewRat(1e6, 1),
+	"G":  big.NewRat(1e9, 1),
+	"T":  big.NewRat(1e12, 1),
+	"P":  big.NewRat(1e15, 1),
+	"E":  big.NewRat(1e18, 1),
+	"Ki": big.NewRat(1<<10, 1),
+	"Mi": big.NewRat(1<<20, 1),
+	"Gi": big.NewRat(1<<30, 1),
+	"Ti": big.NewRat(1<<40, 1),
+	"Pi": big.NewRat(1<<50, 1),
+	"Ei": big.NewRat(1<<60, 1),
+}
+
+// Parse the value as a number followed by a quantity suffix, e.g. "2Mi",
+// returning the value in base units as int64 when it is exact and as float64
+// otherwise.
+func parseQuantity(val string) (interface{}, bool) {
+	num, suffix := splitNumber(val)
+	mult, ok := quantitySuffixes[suffix]
+	if !ok || num == "" {
+		return nil, false
+	}
+	r, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return nil, false
+	}
+	r.Mul(r, mult)
+	if r.IsInt() && r.Num().IsInt64() {
+		return r.Num().Int64(), true
+	}
+	f, _ := r.Float64()
+	return f, true
+}
+
+// Parse the value as a number followed by a byte size suffix, e.g. "10GB"
+// or "10GiB", returning the number of bytes. The decimal suffixes like "GB"
+// stand for powers of 1000, or of 1024 if binary is set, while the IEC
+// suffixes like "GiB" always stand for powers of 1024. Sizes which are not
+// whole numbers of bytes or overflow int64 are not parsed.
+func parseByteSize(val string, binary bool) (int64, bool) {
+	num, suffix := splitNumber(val)
+	mult, ok := byteSizeMultiplier(suffix, binary)
+	if !ok || num == "" {
+		return 0, false
+	}
+	r, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, false
+	}
+	r.Mul(r, mult)
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return 0, false
+	}
+	return r.Num().Int64(), true
+}
+
+// Find the multiplier of the byte size suffix, which is "B" optionally
+// preceded with a decimal prefix, e.g. "KB" or "kB", or an IEC one, e.g.
+// "KiB".
+func byteSizeMultiplier(suffix string, binary bool) (*big.Rat, bool) {
+	var base int64 = 1000
+	switch {
+	case suffix == "B":
+		return big.NewRat(1, 1), true
+	case len(suffix) == 3 && suffix[1:] == "iB":
+		base = 1024
+	case len(suffix) == 2 && suffix[1] == 'B':
+		if binary {
+			base = 1024
+		}
+	default:
+		return nil, false
+	}
+	exp := strings.IndexRune("KMGTPE", unicode.ToUpper(rune(suffix[0]))) + 1
+	if exp == 0 {
+		return nil, false
+	}
+	mult := new(big.Int).Exp(big.NewInt(base), big.NewInt(int64(exp)), nil)
+	return new(big.Rat).SetInt(mult), true
+}
+
+// Quantity is a number with the unit written after it.
+type Quantity struct {
+	Value float64
+	Unit  string
+}
+
+// Split the value into a number and a unit consisting of letters.
+func parseUnit(val string) (Quantity, bool) {
+	num, unit := splitNumber(val)
+	if unit == "" || strings.IndexFunc(unit, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) >= 0 {
+		return Quantity{}, false
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return Quantity{}, false
+	}
+	return Quantity{Value: f, Unit: unit}, true
+}
+
+// Split the value into a leading decimal number and the rest.
+func splitNumber(val string) (num, rest string) {
+	i := 0
+	if i < len(val) && (val[i] == '-' || val[i] == '+') {
+		i++
+	}
+	for i < len(val) && (val[i] >= '0' && val[i] <= '9' || val[i] == '.') {
+		i++
+	}
+	return val[:i], val[i:]
+}
+
+// Check that the value at the path is a number within the bounds provided.
+func checkRange(path string, val interface{}, bounds [2]float64) error {
+	var f float64
+	switch v := val.(type) {
+	case int64:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return fmt.Errorf("value %#v at path \"%s\" is not a number", val, path)
+	}
+	if f < bounds[0] || f > bounds[1] {
+		return fmt.Errorf("value %v at path \"%s\" is out of range [%v, %v]",
+			val, path, bounds[0], bounds[1])
+	}
+	return nil
+}
+
+// LiteralsTable declares the literals recognized as boolean and null values.
+type LiteralsTable struct {
+	True       []string // The literals of the true value
+	False      []string // The literals of the false value
+	Null       []string // The literals of the null value
+	IgnoreCase bool     // Whether the literals are matched ignoring case
+}
+
+var defaultLiterals = LiteralsTable{
+	True:  []string{"true", "True", "TRUE", "t", "T", "1"},
+	False: []string{"false", "False", "FALSE", "f", "F", "0"},
+	Null:  []string{"null", "Null", "NULL"},
+}
+
+// DefaultLiterals returns the English literals table used unless another one
+// is provided with WithLiterals.
+func DefaultLiterals() LiteralsTable {
+	return LiteralsTable{
+		True:       append([]string(nil), defaultLiterals.True...),
+		False:      append([]string(nil), defaultLiterals.False...),
+		Null:       append([]string(nil), defaultLiterals.Null...),
+		IgnoreCase: defaultLiterals.IgnoreCase,
+	}
+}
+
+func (t *LiteralsTable) parseBool(val string) (bool, bool) {
+	if t.matches(t.True, val) {
+		return true, true
+	}
+	if t.matches(t.False, val) {
+		return false, true
+	}
+	return false, false
+}
+
+func (t *LiteralsTable) isNull(val string) bool {
+	return t.matches(t.Null, val)
+}
+
+func (t *LiteralsTable) matches(literals []string, val string) bool {
+	for _, lit := range literals {
+		if lit == val || t.IgnoreCase && strings.EqualFold(lit, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the value begins and ends with matching double or single quotes.
+func isStrippable(val string) bool {
+	return len(val) >= 2 && (val[0] == '"' || val[0] == '\'') && val[len(val)-1] == val[0]
+}
+
+// Check if the value begins and ends with matching Go quotes.
+func isGoQuoted(val string) bool {
+	if len(val) < 2 {
+		return false
+	}
+	q := val[0]
+	return (q == '"' || q == '\'' || q == '`') && val[len(val)-1] == q
+}
+
+// Unquote the value following the Go syntax of quoted literals.
+func goUnquote(val string) (interface{}, error) {
+	s, err := strconv.Unquote(val)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unquote %s, %v", val, err)
+	}
+	return s, nil
+}
+
+// Parse the value following a leading type sigil as the type the sigil
+// stands for, reporting whether the value starts with a sigil.
+func parseSigil(path, val string) (interface{}, bool, error) {
+	if val == "" {
+		return nil, false, nil
+	}
+	rest := val[1:]
+	switch val[0] {
+	case '#':
+		i, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return nil, true, fmt.Errorf("value \"%s\" at path \"%s\" is not an integer", rest, path)
+		}
+		return i, true, nil
+	case '~':
+		f, err := strconv.ParseFloat(rest, 64)
+		if err != nil {
+			return nil, true, fmt.Errorf("value \"%s\" at path \"%s\" is not a float", rest, path)
+		}
+		return f, true, nil
+	case '?':
+		val, err := coerce(path, rest, TypeBool, false)
+		return val, true, err
+	case '$':
+		return rest, true, nil
+	}
+	return nil, false, nil
+}
+
+// Remove the escape of a leading type sigil.
+func unescapeSigil(val string) string {
+	if len(val) >= 2 && val[0] == '\\' && strings.IndexByte("#~?$", val[1]) >= 0 {
+		return val[1:]
+	}
+	return val
+}
+
+// Compile the regular expression following the regexp prefix.
+func compileRegexp(val string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(val[len(regexpPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile \"%s\", %v", val, err)
+	}
+	return re, nil
+}
+
+// Find the longest prefix of the value having a union parser.
+func matchUnionPrefix(parsers map[string]UnionParser, val string) (string, UnionParser, bool) {
+	var prefix string
+	var parse UnionParser
+	for p, f := range parsers {
+		if strings.HasPrefix(val, p) && (parse == nil || len(p) > len(prefix)) {
+			prefix, parse = p, f
+		}
+	}
+	return prefix, parse, parse != nil
+}
+
+// Expand the glob pattern against the file system into an array of the
+// matching names.
+func globValue(fsys fs.FS, pattern string) (interface{}, error) {
+	names, err := fs.Glob(fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("unable to expand \"%s\", %v", pattern, err)
+	}
+	res := make([]interface{}, len(names))
+	for i, name := range names {
+		res[i] = name
+	}
+	return res, nil
+}
+
+// Resolve the file path relative to the base directory unless it is
+// absolute.
+func resolveFilePath(base, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(base, path)
+}
+
+// Check whether a number converted from the literal does not reproduce it
+// when formatted, e.g. "1.10" converted to 1.1 or "007" converted to 7.
+func isLossy(literal string, val interface{}) bool {
+	switch v := val.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10) != literal
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64) != literal
+	default:
+		return false
+	}
+}
+
+// Split the value at the first '#' outside double quotes which starts the
+// value or follows a space, trimming the spaces around the comment.
+func splitComment(val string) (string, string, bool) {
+	quoted := false
+	escaped := false
+	for i, r := range val {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == '#' && !quoted && (i == 0 || unicode.IsSpace(rune(val[i-1]))):
+			return strings.TrimRightFunc(val[:i], unicode.IsSpace),
+				strings.TrimSpace(val[i+1:]), true
+		}
+	}
+	return val, "", false
+}
+
+// Split the value on the separators not escaped with a backslash, returning
+// false if there are none.
+func splitValue(val string, sep rune) ([]string, bool) {
+	var parts []string
+	var sb strings.Builder
+	escaped := false
+	for _, r := range val {
+		switch {
+		case escaped:
+			if r != sep {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == sep:
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if escaped {
+		sb.WriteRune('\\')
+	}
+	if parts == nil {
+		return nil, false
+	}
+	return append(parts, sb.String()), true
+}
+
+// Escape the commas of the value, so that they do not separate assignments.
+func escapeCommas(val string) string {
+	return strings.ReplaceAll(val, ",", "\\,")
+}
+
+// Escape the backslashes and the commas of the value, so that it is lexed
+// literally.
+func escapeValue(val string) string {
+	return escapeCommas(strings.ReplaceAll(val, "\\", "\\\\"))
+}
+
+// Enclose the value in double quotes escaping the backslashes and the double
+// quotes, so that it is lexed as a quoted value.
+func quoteValue(val string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(val) + `"`
+}
+
+// Render the string as a value lexed literally. It is enclosed in double
+// quotes when it contains brackets, braces, double quotes or backslashes,
+// whose meaning depends on where they appear, and its commas are escaped
+// otherwise.
+func renderValue(val string) string {
+	if strings.ContainsAny(val, `[{"\`) {
+		return quoteValue(val)
+	}
+	return escapeCommas(val)
+}
+
+// Escape the commas and the closing braces of the element, so that it is
+// split from a brace list literally.
+func escapeBraceItem(val string) string {
+	return strings.NewReplacer(",", "\\,", "}", "\\}").Replace(val)
+}
+
+// Check if the value begins and ends with curly braces.
+func isBraced(val string) bool {
+	return len(val) >= 2 && val[0] == '{' && val[len(val)-1] == '}'
+}
+
+// Check if the value begins with a curly brace and ends with an unescaped
+// one.
+func isBraceList(val string) bool {
+	if !isBraced(val) {
+		return false
+	}
+	escapes := len(val) - 1 - len(strings.TrimRight(val[:len(val)-1], "\\"))
+	return escapes%2 == 0
+}
+
+// Split the content of a brace list on the commas not escaped with a
+// backslash, unescaping the commas and the closing braces. An empty content
+// is an empty list.
+func splitBraceList(val string) []string {
+	if val == "" {
+		return []string{}
+	}
+	var items []string
+	var sb strings.Builder
+	escaped := false
+	for _, r := range val {
+		switch {
+		case escaped:
+			if r != ',' && r != '}' {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			items = append(items, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	if escaped {
+		sb.WriteRune('\\')
+	}
+	return append(items, sb.String())
+}
+
+// Fold the lines of a multi-line value the way YAML block scalars do. With
+// stripIndent the indentation common to the lines which are not blank is
+// removed, and with fold the lines are joined with spaces, every run of blank
+// lines between them becoming a single new line. Values of a single line are
+// kept as they are.
+func foldBlock(val string, fold, stripIndent bool) string {
+	if !strings.Contains(val, "\n") {
+		return val
+	}
+	lines := strings.Split(val, "\n")
+	if stripIndent {
+		indent := commonIndent(lines)
+		for i, line := range lines {
+			if isBlank(line) {
+				lines[i] = ""
+			} else {
+				lines[i] = line[len(indent):]
+			}
+		}
+	}
+	if !fold {
+		return strings.Join(lines, "\n")
+	}
+	var sb strings.Builder
+	blank := false
+	for _, line := range lines {
+		switch {
+		case isBlank(line):
+			blank = sb.Len() > 0
+			continue
+		case blank:
+			sb.WriteByte('\n')
+		case sb.Len() > 0:
+			sb.WriteByte(' ')
+		}
+		blank = false
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// Find the longest run of spaces and tabs starting all the lines which are
+// not blank.
+func commonIndent(lines []string) string {
+	var indent string
+	found := false
+	for _, line := range lines {
+		if isBlank(line) {
+			continue
+		}
+		cur := line[:strings.IndexFunc(line, func(r rune) bool { return r != ' ' && r != '\t' })]
+		if !found {
+			indent, found = cur, true
+			continue
+		}
+		for !strings.HasPrefix(cur, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	return indent
+}
+
+// Check if the line consists of whitespace only.
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// Convert the strings to values of an array.
+func toValues(vals []string) []interface{} {
+	a := make([]interface{}, len(vals))
+	for i, val := range vals {
+		a[i] = val
+	}
+	return a
+}
+
+// Split the content of a braced value on the semicolons outside nested
+// braces, trimming the spaces around the fields. Escaped semicolons are kept
+// unescaped, except inside nested braces where the nested fields are split.
+func splitFields(val string) []string {
+	var fields []string
+	var sb strings.Builder
+	depth := 0
+	escaped := false
+	for _, r := range val {
+		switch {
+		case escaped:
+			if r != ';' || depth > 0 {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';' && depth == 0:
+			fields = append(fields, strings.TrimSpace(sb.String()))
+			sb.Reset()
+		default:
+			switch r {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			sb.WriteRune(r)
+		}
+	}
+	if escaped {
+		sb.WriteRune('\\')
+	}
+	return append(fields, strings.TrimSpace(sb.String()))
+}
+
+// Replace the escaped separators of the value with the separators.
+func unescapeSeparator(val string, sep rune) string {
+	return strings.ReplaceAll(val, "\\"+string(sep), string(sep))
+}
+
+// Parse the bracketed matrix literal into nested arrays, converting the
+// elements with the function provided.
+func parseMatrix(val string, convert func(string) interface{}) ([]interface{}, error) {
+	a, rest, err := parseMatrixArray(val, convert)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("unexpected \"%s\" after the matrix", rest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid matrix \"%s\", %v", val, err)
+	}
+	return a, nil
+}
+
+func parseMatrixArray(val string, convert func(string) interface{}) ([]interface{}, string, error) {
+	if !strings.HasPrefix(val, "[") {
+		return nil, "", errors.New("expecting '['")
+	}
+	rest := strings.TrimLeftFunc(val[1:], unicode.IsSpace)
+	a := []interface{}{}
+	if strings.HasPrefix(rest, "]") {
+		return a, rest[1:], nil
+	}
+	for {
+		var e interface{}
+		if strings.HasPrefix(rest, "[") {
+			var err error
+			if e, rest, err = parseMatrixArray(rest, convert); err != nil {
+				return nil, "", err
+			}
+			rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		} else {
+			i := strings.IndexAny(rest, ",[]")
+			if i < 0 {
+				return nil, "", errors.New("expecting ']'")
+			}
+			e = convert(strings.TrimSpace(rest[:i]))
+			rest = rest[i:]
+		}
+		a = append(a, e)
+		switch {
+		case strings.HasPrefix(rest, ","):
+			rest = strings.TrimLeftFunc(rest[1:], unicode.IsSpace)
+		case strings.HasPrefix(rest, "]"):
+			return a, rest[1:], nil
+		default:
+			return nil, "", errors.New("expecting ',' or ']'")
+		}
+	}
+}
+
+// Split the value into words separated by spaces the way a shell does,
+// honoring single and double quotes and escapes with a backslash.
+func shellSplit(val string) ([]interface{}, error) {
+	words := []interface{}{}
+	var sb strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range val {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, sb.String())
+				sb.Reset()
+				inWord = false
+			}
+		default:
+			sb.WriteRune(r)
+			inWord = true
+		}
+	}
+	switch {
+	case escaped:
+		return nil, fmt.Errorf("unable to split \"%s\", incomplete escape sequence", val)
+	case quote != 0:
+		return nil, fmt.Errorf("unable to split \"%s\", unterminated quote", val)
+	case inWord:
+		words = append(words, sb.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,129 @@
+package djson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version without pre-release and build metadata.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// Constraint is a comparison of versions to a version, e.g. ">=1.2.0". The
+// operators are "=", "<", "<=", ">", ">=", "~" allowing the patches of the
+// version and "^" allowing the changes not modifying the leftmost non-zero
+// number of the version.
+type Constraint struct {
+	Op      string
+	Version Version
+}
+
+// Constraints are constraints all versions allowed must satisfy.
+type Constraints []Constraint
+
+// ParseVersion parses a version like "1.2.3" or "v1.2", with the missing
+// numbers taken for zeros.
+func ParseVersion(str string) (Version, error) {
+	parts := strings.Split(strings.TrimPrefix(str, "v"), ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version \"%s\"", str)
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || part != strconv.Itoa(n) {
+			return Version{}, fmt.Errorf("invalid version \"%s\"", str)
+		}
+		nums[i] = n
+	}
+	return Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// The operators ordered so that the longer ones are matched first.
+var constraintOps = []string{">=", "<=", "=", "<", ">", "~", "^"}
+
+// ParseConstraints parses the constraints separated by spaces, e.g.
+// ">=1.2.0 <2.0.0". A version without an operator is an exact constraint.
+func ParseConstraints(str string) (Constraints, error) {
+	fields := strings.Fields(str)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid constraint \"%s\"", str)
+	}
+	cs := make(Constraints, 0, len(fields))
+	for _, field := range fields {
+		op := "="
+		for _, o := range constraintOps {
+			if strings.HasPrefix(field, o) {
+				op = o
+				break
+			}
+		}
+		v, err := ParseVersion(strings.TrimPrefix(field, op))
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint \"%s\", %v", str, err)
+		}
+		cs = append(cs, Constraint{Op: op, Version: v})
+	}
+	return cs, nil
+}
+
+// Compare returns -1, 0 or 1 if the version is lower than, equal to or
+// greater than the other one.
+func (v Version) Compare(other Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// String renders the version as "major.minor.patch".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Allows checks whether the version satisfies the constraint.
+func (c Constraint) Allows(v Version) bool {
+	cmp := v.Compare(c.Version)
+	switch c.Op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "~":
+		return cmp >= 0 && v.Major == c.Version.Major && v.Minor == c.Version.Minor
+	case "^":
+		switch {
+		case cmp < 0 || v.Major != c.Version.Major:
+			return false
+		case c.Version.Major > 0:
+			return true
+		case c.Version.Minor > 0:
+			return v.Minor == c.Version.Minor
+		default:
+			return v.Minor == 0 && v.Patch == c.Version.Patch
+		}
+	default:
+		return cmp == 0
+	}
+}
+
+// Allows checks whether the version satisfies all the constraints.
+func (cs Constraints) Allows(v Version) bool {
+	for _, c := range cs {
+		if !c.Allows(v) {
+			return false
+		}
+	}
+	return true
+}
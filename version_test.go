@@ -0,0 +1,91 @@
+package djson
+
+import (
+	"testing"
+)
+
+func Test_Parser_Parses_Version_Constraints(t *testing.T) {
+	testCases := []parserTestCase{
+		newParserTestCase(
+			"a range constraint", "version=>=1.2.0 <2.0.0",
+			map[string]interface{}{
+				"version": Constraints{
+					{Op: ">=", Version: Version{Major: 1, Minor: 2}},
+					{Op: "<", Version: Version{Major: 2}},
+				},
+			},
+		),
+		newParserTestCase(
+			"an exact version", "version=v1.4.2",
+			map[string]interface{}{
+				"version": Constraints{
+					{Op: "=", Version: Version{Major: 1, Minor: 4, Patch: 2}},
+				},
+			},
+		),
+		newParserTestCase(
+			"a version at another path", "other=1.2",
+			map[string]interface{}{
+				"other": 1.2,
+			},
+		),
+	}
+	for _, test := range testCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithVersionConstraints("version"))
+		assertNoError(t, err, test, m)
+	}
+
+	errorTestCases := []parserErrorTestCase{
+		newParserErrorTestCase(
+			"an invalid constraint", "version=>=1.x",
+			"unable to parse \"version=>=1.x\", invalid constraint \">=1.x\", invalid version \"1.x\"",
+		),
+		newParserErrorTestCase(
+			"a pre-release version", "version=1.2.0-rc1",
+			"unable to parse \"version=1.2.0-rc1\", invalid constraint \"1.2.0-rc1\", invalid version \"1.2.0-rc1\"",
+		),
+	}
+	for _, test := range errorTestCases {
+		m := map[string]interface{}{}
+		err := MergeValue(m, test.input, WithVersionConstraints("version"))
+		assertError(t, err, test)
+	}
+}
+
+func Test_Constraints_Allow_Versions(t *testing.T) {
+	testCases := []struct {
+		constraints string
+		version     string
+		expected    bool
+	}{
+		{">=1.2.0 <2.0.0", "1.2.0", true},
+		{">=1.2.0 <2.0.0", "1.9.9", true},
+		{">=1.2.0 <2.0.0", "2.0.0", false},
+		{">=1.2.0 <2.0.0", "1.1.9", false},
+		{"1.4.2", "1.4.2", true},
+		{"1.4.2", "1.4.3", false},
+		{">1.0 <=1.1", "1.1.0", true},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.2.5", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+	}
+	for _, test := range testCases {
+		cs, err := ParseConstraints(test.constraints)
+		if err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", test.constraints, err)
+		}
+		v, err := ParseVersion(test.version)
+		if err != nil {
+			t.Fatalf("Expected success for \"%s\", got %v", test.version, err)
+		}
+		if allowed := cs.Allows(v); allowed != test.expected {
+			t.Errorf("Expected \"%s\" allowing %s to be %t, got %t",
+				test.constraints, v, test.expected, allowed)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package djson
+
+import (
+	"sort"
+)
+
+// SortedKeys returns the keys of the map provided in ascending order.
+func SortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Walk visits the leaves of the map provided in sorted path order calling fn
+// with the path and the value of every leaf. Map keys are visited in
+// ascending order and array elements in the order of their indices. The
+// paths are rendered in the DJSON syntax with the special characters of the
+// keys escaped, e.g. "foo\.bar[0].baz". Empty maps and arrays are
+// considered leaves.
+func Walk(m map[string]interface{}, fn func(path string, value interface{})) {
+	walkMap("", m, fn)
+}
+
+func walkMap(path string, m map[string]interface{}, fn func(string, interface{})) {
+	for _, key := range SortedKeys(m) {
+		walkValue(appendKey(path, key), m[key], fn)
+	}
+}
+
+func walkArray(path string, a []interface{}, fn func(string, interface{})) {
+	for i, val := range a {
+		walkValue(appendIndex(path, i), val, fn)
+	}
+}
+
+func walkValue(path string, val interface{}, fn func(string, interface{})) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			walkMap(path, v, fn)
+			return
+		}
+	case []interface{}:
+		if len(v) > 0 {
+			walkArray(path, v, fn)
+			return
+		}
+	}
+	fn(path, val)
+}
+
+// Flatten returns a copy of the map provided keeping at most maxDepth levels
+// of nested maps. The maps deeper than that are collapsed into keys rendered
+// as paths in the DJSON syntax, with the arrays inside them rendered using
+// bracket indices, e.g. with maxDepth 1 the map {"a": {"b": [1]}} becomes
+// {"a.b[0]": 1}. Values other than maps are kept intact at the levels being
+// kept. The maxDepth less than 1 is treated as 1.
+func Flatten(m map[string]interface{}, maxDepth int) map[string]interface{} {
+	res := map[string]interface{}{}
+	for key, val := range m {
+		nested, ok := val.(map[string]interface{})
+		switch {
+		case !ok:
+			res[key] = val
+		case maxDepth > 1:
+			res[key] = Flatten(nested, maxDepth-1)
+		default:
+			walkValue(appendKey("", key), nested, func(path string, val interface{}) {
+				res[path] = val
+			})
+		}
+	}
+	return res
+}
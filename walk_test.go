@@ -0,0 +1,124 @@
+package djson
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_SortedKeys(t *testing.T) {
+	m := map[string]interface{}{
+		"b": 1,
+		"c": 2,
+		"a": 3,
+	}
+	expected := []string{"a", "b", "c"}
+	if keys := SortedKeys(m); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected %v, got %v", expected, keys)
+	}
+}
+
+func Test_Walk_Visits_Leaves_In_Sorted_Order(t *testing.T) {
+	m := map[string]interface{}{
+		"zoo": "z",
+		"foo": map[string]interface{}{
+			"bar": []interface{}{
+				int64(1),
+				map[string]interface{}{
+					"b": true,
+					"a": nil,
+				},
+			},
+			"baz.qux": "escaped",
+			"empty":   map[string]interface{}{},
+		},
+		"abc": []interface{}{},
+	}
+
+	type leaf struct {
+		path  string
+		value interface{}
+	}
+	var leaves []leaf
+	Walk(m, func(path string, value interface{}) {
+		leaves = append(leaves, leaf{path, value})
+	})
+
+	expected := []leaf{
+		{"abc", []interface{}{}},
+		{"foo.bar[0]", int64(1)},
+		{"foo.bar[1].a", nil},
+		{"foo.bar[1].b", true},
+		{"foo.baz\\.qux", "escaped"},
+		{"foo.empty", map[string]interface{}{}},
+		{"zoo", "z"},
+	}
+	if !reflect.DeepEqual(leaves, expected) {
+		t.Errorf("Expected %v, got %v", expected, leaves)
+	}
+}
+
+func Test_Flatten(t *testing.T) {
+	m := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": map[string]interface{}{
+				"c": int64(1),
+				"d": []interface{}{"x", "y"},
+			},
+			"e": "z",
+		},
+		"f": []interface{}{
+			map[string]interface{}{
+				"g": true,
+			},
+		},
+		"h": nil,
+	}
+
+	testCases := []struct {
+		maxDepth int
+		expected map[string]interface{}
+	}{
+		{
+			1,
+			map[string]interface{}{
+				"a.b.c":    int64(1),
+				"a.b.d[0]": "x",
+				"a.b.d[1]": "y",
+				"a.e":      "z",
+				"f": []interface{}{
+					map[string]interface{}{
+						"g": true,
+					},
+				},
+				"h": nil,
+			},
+		},
+		{
+			2,
+			map[string]interface{}{
+				"a": map[string]interface{}{
+					"b.c":    int64(1),
+					"b.d[0]": "x",
+					"b.d[1]": "y",
+					"e":      "z",
+				},
+				"f": []interface{}{
+					map[string]interface{}{
+						"g": true,
+					},
+				},
+				"h": nil,
+			},
+		},
+		{
+			3,
+			m,
+		},
+	}
+	for _, test := range testCases {
+		res := Flatten(m, test.maxDepth)
+		if !reflect.DeepEqual(res, test.expected) {
+			t.Errorf("In the case of depth %d expected:\n\t%v\ngot:\n\t%v", test.maxDepth, test.expected, res)
+		}
+	}
+}